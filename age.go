@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// ageRange is the oldest and newest modification time seen in a set of files
+type ageRange struct {
+	Oldest time.Time
+	Newest time.Time
+}
+
+// add widens the range to include t
+func (a *ageRange) add(t time.Time) {
+	if a.Oldest.IsZero() || t.Before(a.Oldest) {
+		a.Oldest = t
+	}
+	if a.Newest.IsZero() || t.After(a.Newest) {
+		a.Newest = t
+	}
+}
+
+// merge widens the range to include o
+func (a *ageRange) merge(o ageRange) {
+	if o.Oldest.IsZero() {
+		return
+	}
+	a.add(o.Oldest)
+	a.add(o.Newest)
+}
+
+// String formats the range as RFC3339 timestamps plus the spread between them
+func (a ageRange) String() string {
+	return fmt.Sprintf("oldest %s, newest %s, spread %s",
+		a.Oldest.Format(time.RFC3339), a.Newest.Format(time.RFC3339),
+		formatSpread(a.Newest.Sub(a.Oldest)))
+}
+
+// formatSpread renders d with whole days split off, e.g. "12d3h0m5s",
+// matching the "7d" form accepted by --newer-than
+func formatSpread(d time.Duration) string {
+	d = d.Round(time.Second)
+	days := d / (24 * time.Hour)
+	if days == 0 {
+		return d.String()
+	}
+	rest := d - days*24*time.Hour
+	if rest == 0 {
+		return fmt.Sprintf("%dd", days)
+	}
+	return fmt.Sprintf("%dd%s", days, rest)
+}
+
+// printAges prints the modification time range of the whole scan and, when
+// --age-per-ext collected them, of every row in name order
+func printAges(w io.Writer, all ageRange, keyAges map[string]ageRange) {
+	if all.Oldest.IsZero() {
+		return
+	}
+	fmt.Fprintf(w, "Modified: %s\n", all)
+	keys := make([]string, 0, len(keyAges))
+	for k := range keyAges {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(w, "    %-10s %s\n", k, keyAges[k])
+	}
+}
+
+// ageBuckets are the --age-buckets classes, newest first
+// A file falls in the first bucket whose Within exceeds its age; the last one catches the rest
+var ageBuckets = []struct {
+	Label  string
+	Within time.Duration
+}{
+	{"today", 24 * time.Hour},
+	{"this week", 7 * 24 * time.Hour},
+	{"this month", 30 * 24 * time.Hour},
+	{"this year", 365 * 24 * time.Hour},
+	{"older", 0},
+}
+
+// ageBucket returns the index in ageBuckets of a file modified at mtime
+// Files dated in the future count as modified today
+func ageBucket(now, mtime time.Time) int {
+	age := now.Sub(mtime)
+	for i, b := range ageBuckets[:len(ageBuckets)-1] {
+		if age < b.Within {
+			return i
+		}
+	}
+	return len(ageBuckets) - 1
+}
+
+// printAgeBuckets prints how many files, and how many bytes, fall in each --age-buckets class
+func printAgeBuckets(w io.Writer, cfg Config, totals []dirTotal) {
+	if len(totals) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "Age buckets:")
+	for i, b := range ageBuckets {
+		fmt.Fprintf(w, "    %-11s %8s %10s\n", b.Label, formatCount(cfg, int64(totals[i].Count)), formatSize(cfg, totals[i].Size))
+	}
+}
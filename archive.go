@@ -0,0 +1,74 @@
+package main
+
+import (
+	"archive/zip"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// isZip reports whether a file should be opened by --scan-archives
+func isZip(name string) bool {
+	return strings.EqualFold(filepath.Ext(name), ".zip")
+}
+
+// archiveReason returns why the archive at path is skipped as a whole, or ""
+// Path and extension exclusions apply to the archive itself; size, time and
+// --include filters are left to its entries
+func archiveReason(cfg Config, path string, info fs.FileInfo) string {
+	if reason := pathReason(cfg, path, info.Name()); reason != "" {
+		return reason
+	}
+	if _, skip := cfg.Exclude[fileExt(cfg, info.Name())]; skip {
+		return "excluded extension"
+	}
+	return ""
+}
+
+// addZip counts the entries of the zip archive at zipPath instead of the archive itself
+// Entries go through the same filters as files on disk and are reported as
+// zipPath/entry; archives inside the archive are counted as plain files
+// It returns false if the file cannot be read as a zip so the caller counts it as is
+func (r *ScanResult) addZip(cfg Config, zipPath string) bool {
+	zr, err := zip.OpenReader(zipPath)
+	if err != nil {
+		tracef(cfg, "plain    %s (not a readable zip: %v)", zipPath, err)
+		return false
+	}
+	defer zr.Close()
+	tracef(cfg, "archive  %s", zipPath)
+
+	// Features that reopen the file by path cannot see inside the archive
+	sub := cfg
+	sub.ScanArchives = false
+	sub.Sniff = false
+	sub.Lines = false
+	sub.Dupes = false
+	sub.Manifest = false
+
+	before := 0
+	if r != nil {
+		before = r.Total
+	}
+	for _, f := range zr.File {
+		info := f.FileInfo()
+		if info.IsDir() {
+			continue
+		}
+		entry := zipPath + string(filepath.Separator) + filepath.FromSlash(f.Name)
+		if reason := hiddenReason(cfg, path.Base(f.Name)); reason != "" {
+			tracef(cfg, "skip     %s (%s)", entry, reason)
+			continue
+		}
+		r.add(sub, entry, info)
+	}
+	if r != nil {
+		r.Archives++
+		r.ArchiveEntries += r.Total - before
+		if cfg.Manifest {
+			r.Hashable = append(r.Hashable, zipPath)
+		}
+	}
+	return true
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// loadBaseline reads a report previously written by --json into a ScanResult
+// holding just its per-row counts and sizes
+func loadBaseline(path string) (*ScanResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var report JSONReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, err
+	}
+	res := newScanResult()
+	for _, s := range report.Stats {
+		res.Counts[s.Ext] += s.Count
+		res.SizeCounts[s.Ext] += s.Size
+		res.Total += s.Count
+		res.TotalBytes += s.Size
+	}
+	return res, nil
+}
+
+// statsResult turns aggregated rows back into a ScanResult so the current
+// scan is compared after the same --top and --threshold folding as the baseline
+func statsResult(stats []FileStat) *ScanResult {
+	res := newScanResult()
+	for _, s := range stats {
+		res.Counts[s.Ext] += s.Count
+		res.SizeCounts[s.Ext] += s.Size
+		res.Total += s.Count
+		res.TotalBytes += s.Size
+	}
+	return res
+}
+
+// baselineDrift returns the rows whose count or size moved by more than
+// --tolerance percent from base; added and removed rows always count
+func baselineDrift(cfg Config, base, current *ScanResult) []diffRow {
+	drifted := []diffRow{}
+	for _, r := range diffResults(base, current) {
+		if r.Added || r.Removed ||
+			driftPercent(int64(r.Count), int64(base.Counts[r.Key])) > cfg.Tolerance ||
+			driftPercent(r.SizeDelta, base.SizeCounts[r.Key]) > cfg.Tolerance {
+			drifted = append(drifted, r)
+		}
+	}
+	return drifted
+}
+
+// driftPercent returns delta as a percentage of base
+func driftPercent(delta, base int64) float64 {
+	return safeDivF(float64(abs64(delta)), float64(base)) * 100
+}
+
+// printDrift lists the rows that drifted from the --baseline report
+func printDrift(w io.Writer, cfg Config, rows []diffRow) {
+	fmt.Fprintf(w, "Drift beyond %g%% from %s:\n", cfg.Tolerance, cfg.Baseline)
+	for _, r := range rows {
+		printDiffRow(w, cfg, r)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// cacheEntry is what --cache remembers about one file between runs
+type cacheEntry struct {
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"mtime"`
+	SHA256  string `json:"sha256,omitempty"`
+}
+
+// scanCache holds the --cache entries loaded from the previous run and the
+// ones seen by this run, which take precedence when saved
+// Files whose size or mtime changed lose their cached hash
+type scanCache struct {
+	path string
+
+	mu   sync.Mutex
+	prev map[string]cacheEntry
+	cur  map[string]cacheEntry
+}
+
+// loadCache reads the --cache file at path
+// A missing file is not an error: it is created when the run ends
+func loadCache(path string) (*scanCache, error) {
+	c := &scanCache{path: path, prev: make(map[string]cacheEntry), cur: make(map[string]cacheEntry)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &c.prev); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// observe records a counted file, keeping its cached hash only if the size
+// and mtime still match the previous run
+func (c *scanCache) observe(path string, info fs.FileInfo) {
+	e := cacheEntry{Size: info.Size(), ModTime: info.ModTime().UnixNano()}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if old, ok := c.prev[path]; ok && old.Size == e.Size && old.ModTime == e.ModTime {
+		e.SHA256 = old.SHA256
+	}
+	c.cur[path] = e
+}
+
+// hash returns the cached digest of path, if it is still valid
+// A nil cache never has one
+func (c *scanCache) hash(path string) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.cur[path]
+	return e.SHA256, ok && e.SHA256 != ""
+}
+
+// setHash stores the digest of a file observed in this run
+func (c *scanCache) setHash(path, sum string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.cur[path]; ok {
+		e.SHA256 = sum
+		c.cur[path] = e
+	}
+}
+
+// save writes the entries seen by this run plus the previous ones it did not
+// see, so filtered, truncated or interrupted runs keep the rest of the cache
+// Only entries for files that no longer exist are dropped
+// The file is replaced atomically so a failed write keeps the old cache
+func (c *scanCache) save() error {
+	c.mu.Lock()
+	entries := make(map[string]cacheEntry, len(c.prev)+len(c.cur))
+	for path, e := range c.prev {
+		if _, err := os.Lstat(path); err == nil {
+			entries[path] = e
+		}
+	}
+	for path, e := range c.cur {
+		entries[path] = e
+	}
+	data, err := json.Marshal(entries)
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), ".dstat-cache-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path)
+}
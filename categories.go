@@ -0,0 +1,72 @@
+package main
+
+import "strings"
+
+// extCategories maps lowercase extensions to the semantic bucket used by --categories
+// Extensions missing from this table are reported as "other"
+var extCategories = map[string]string{
+	// code
+	"go":    "code",
+	"py":    "code",
+	"js":    "code",
+	"ts":    "code",
+	"jsx":   "code",
+	"tsx":   "code",
+	"rs":    "code",
+	"c":     "code",
+	"h":     "code",
+	"cpp":   "code",
+	"hpp":   "code",
+	"cc":    "code",
+	"java":  "code",
+	"kt":    "code",
+	"rb":    "code",
+	"php":   "code",
+	"cs":    "code",
+	"swift": "code",
+	"sh":    "code",
+	"lua":   "code",
+
+	// image
+	"png":  "image",
+	"jpg":  "image",
+	"jpeg": "image",
+	"gif":  "image",
+	"bmp":  "image",
+	"svg":  "image",
+	"webp": "image",
+	"ico":  "image",
+	"tiff": "image",
+
+	// document
+	"pdf":  "document",
+	"doc":  "document",
+	"docx": "document",
+	"md":   "document",
+	"txt":  "document",
+	"rtf":  "document",
+	"odt":  "document",
+	"xls":  "document",
+	"xlsx": "document",
+	"ppt":  "document",
+	"pptx": "document",
+
+	// archive
+	"zip": "archive",
+	"tar": "archive",
+	"gz":  "archive",
+	"tgz": "archive",
+	"bz2": "archive",
+	"xz":  "archive",
+	"7z":  "archive",
+	"rar": "archive",
+	"zst": "archive",
+}
+
+// extCategory returns the semantic bucket for an extension
+func extCategory(ext string) string {
+	if c, ok := extCategories[strings.ToLower(ext)]; ok {
+		return c
+	}
+	return "other"
+}
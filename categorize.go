@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// categorizer groups files by the first line an external --categorize-cmd prints
+// Results are cached per extension unless --categorize-per-file is set
+type categorizer struct {
+	argv    []string
+	perFile bool
+	quiet   bool
+
+	mu     sync.Mutex
+	cache  map[string]string
+	warned sync.Once
+}
+
+// newCategorizer splits cmd into a program and its leading arguments
+func newCategorizer(cmd string, perFile, quiet bool) (*categorizer, error) {
+	argv := strings.Fields(cmd)
+	if len(argv) == 0 {
+		return nil, errors.New("empty command")
+	}
+	return &categorizer{argv: argv, perFile: perFile, quiet: quiet, cache: make(map[string]string)}, nil
+}
+
+// key returns the grouping key for the file at path with extension ext
+// A failing command falls back to ext; the first failure is reported once
+func (c *categorizer) key(path, ext string) string {
+	if c.perFile {
+		return c.run(path, ext)
+	}
+	// Holding the lock while running makes concurrent workers share one fork per extension
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if k, ok := c.cache[ext]; ok {
+		return k
+	}
+	k := c.run(path, ext)
+	c.cache[ext] = k
+	return k
+}
+
+// run executes the command with path appended and returns its first output line
+func (c *categorizer) run(path, ext string) string {
+	out, err := exec.Command(c.argv[0], append(c.argv[1:], path)...).Output()
+	line := ""
+	if err == nil {
+		sc := bufio.NewScanner(bytes.NewReader(out))
+		if sc.Scan() {
+			line = strings.TrimSpace(sc.Text())
+		}
+		if line == "" {
+			err = errors.New("no output")
+		}
+	}
+	if err != nil {
+		c.warned.Do(func() {
+			if !c.quiet {
+				fmt.Fprintf(os.Stderr, "Warning: --categorize-cmd failed for %s (%v); falling back to extensions\n", path, err)
+			}
+		})
+		return ext
+	}
+	return line
+}
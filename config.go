@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// configPath returns the value of the last --config flag in args, if any
+func configPath(args []string) string {
+	var path string
+	for i := 0; i < len(args); i++ {
+		if v, ok := strings.CutPrefix(args[i], "--config="); ok {
+			path = v
+		} else if args[i] == "--config" && i+1 < len(args) {
+			i++
+			path = args[i]
+		}
+	}
+	return path
+}
+
+// loadConfigFile reads a JSON config file and turns it into equivalent flags
+// Keys are long option names without dashes, e.g.
+//
+//	{"exclude": ["png", "jpg"], "excludedir": ".git", "maxdepth": 3, "human": true}
+//
+// The special key "dir" sets the directory to scan. Booleans map to bare
+// flags (false is a no-op), numbers and strings become --key=value, and
+// string arrays repeat the flag once per item
+func loadConfigFile(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config: %v", err)
+	}
+
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parsing config %s: %v", path, err)
+	}
+
+	// Sort keys so repeated runs apply options in the same order
+	keys := make([]string, 0, len(raw))
+	for k := range raw {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var args []string
+	for _, k := range keys {
+		flag := "--" + strings.TrimLeft(k, "-")
+		switch v := raw[k].(type) {
+		case bool:
+			if k == "dir" {
+				return nil, fmt.Errorf("config %s: %q must be a string", path, k)
+			}
+			if v {
+				args = append(args, flag)
+			}
+		case float64:
+			args = append(args, flag+"="+strconv.FormatFloat(v, 'f', -1, 64))
+		case string:
+			if k == "dir" {
+				args = append(args, v)
+			} else {
+				args = append(args, flag+"="+v)
+			}
+		case []any:
+			for _, item := range v {
+				str, ok := item.(string)
+				if !ok {
+					return nil, fmt.Errorf("config %s: %q must be a list of strings", path, k)
+				}
+				if k == "dir" {
+					args = append(args, str)
+				} else {
+					args = append(args, flag+"="+str)
+				}
+			}
+		default:
+			return nil, fmt.Errorf("config %s: unsupported value for %q", path, k)
+		}
+	}
+	return args, nil
+}
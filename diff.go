@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// diffRow is the change of one row between the base scan and the --diff scan
+type diffRow struct {
+	Key       string
+	Count     int
+	Size      int64
+	Added     bool
+	Removed   bool
+	SizeDelta int64
+}
+
+// diffResults compares base against other row by row
+// Rows that did not change are dropped; the rest are ordered by size change
+func diffResults(base, other *ScanResult) []diffRow {
+	keys := make(map[string]struct{})
+	for k := range base.Counts {
+		keys[k] = struct{}{}
+	}
+	for k := range other.Counts {
+		keys[k] = struct{}{}
+	}
+
+	rows := []diffRow{}
+	for k := range keys {
+		row := diffRow{
+			Key:       k,
+			Count:     other.Counts[k] - base.Counts[k],
+			SizeDelta: other.SizeCounts[k] - base.SizeCounts[k],
+			Added:     base.Counts[k] == 0,
+			Removed:   other.Counts[k] == 0,
+		}
+		if row.Count == 0 && row.SizeDelta == 0 {
+			continue
+		}
+		rows = append(rows, row)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		a, b := abs64(rows[i].SizeDelta), abs64(rows[j].SizeDelta)
+		if a != b {
+			return a > b
+		}
+		return rows[i].Key < rows[j].Key
+	})
+	return rows
+}
+
+// printDiff prints the per-row changes from base to other followed by the overall change
+func printDiff(w io.Writer, cfg Config, base, other *ScanResult) {
+	rows := diffResults(base, other)
+	if len(rows) == 0 {
+		fmt.Fprintln(w, "No differences.")
+		return
+	}
+
+	fmt.Fprintf(w, "Changes from %s to %s:\n", cfg.Dir, cfg.DiffDir)
+	for _, r := range rows {
+		printDiffRow(w, cfg, r)
+	}
+	fmt.Fprintf(w, "Total: %+d files, %s\n", other.Total-base.Total, signedSize(cfg, other.TotalBytes-base.TotalBytes))
+}
+
+// printDiffRow prints the count and size change of a single row
+func printDiffRow(w io.Writer, cfg Config, r diffRow) {
+	note := ""
+	switch {
+	case r.Added:
+		note = " (added)"
+	case r.Removed:
+		note = " (removed)"
+	}
+	fmt.Fprintf(w, "%-10s %+6d files, %s%s\n", r.Key, r.Count, signedSize(cfg, r.SizeDelta), note)
+}
+
+// signedSize formats a size change with an explicit sign
+func signedSize(cfg Config, delta int64) string {
+	switch {
+	case delta > 0:
+		return "+" + formatSize(cfg, delta)
+	case delta < 0:
+		return "-" + formatSize(cfg, -delta)
+	}
+	return formatSize(cfg, 0)
+}
+
+// abs64 returns the absolute value of n
+func abs64(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
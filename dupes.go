@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"sync"
+)
+
+// DupeGroup is a set of files with identical content
+type DupeGroup struct {
+	Size  int64
+	Hash  string
+	Paths []string
+}
+
+// Wasted returns the bytes that could be reclaimed by keeping a single copy
+func (g DupeGroup) Wasted() int64 {
+	return g.Size * int64(len(g.Paths)-1)
+}
+
+// hashJob is a file queued for hashing and, once done, its digest
+type hashJob struct {
+	path string
+	sum  string
+	err  error
+}
+
+// hashFiles returns the SHA-256 of every readable path, keyed by path
+// Files are hashed by up to cfg.Workers goroutines; results are collected on the caller's goroutine
+// Unreadable files are reported and left out, and hashes still valid in
+// the --cache file are reused instead of reading the file again
+// Cancelling ctx stops queueing files; the result is then partial and complete is false
+func hashFiles(ctx context.Context, cfg Config, paths []string) (sums map[string]string, complete bool) {
+	workers := cfg.Workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	jobs := make(chan hashJob)
+	results := make(chan hashJob)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				if sum, ok := cfg.cache.hash(job.path); ok {
+					job.sum = sum
+				} else {
+					job.sum, job.err = hashFile(job.path)
+				}
+				results <- job
+			}
+		}()
+	}
+	complete = true
+	go func() {
+	queue:
+		for _, path := range paths {
+			select {
+			case jobs <- hashJob{path: path}:
+			case <-ctx.Done():
+				complete = false
+				break queue
+			}
+		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+
+	sums = make(map[string]string, len(paths))
+	for job := range results {
+		if job.err != nil {
+			warnSkip(cfg, job.path, job.err)
+			continue
+		}
+		sums[job.path] = job.sum
+		cfg.cache.setHash(job.path, job.sum)
+	}
+	return sums, complete
+}
+
+// findDupes hashes every size collision in bySize and returns the groups of identical files
+// Unique sizes are never hashed, and empty files are ignored since they waste nothing
+// complete is false if ctx was cancelled before every candidate was hashed
+func findDupes(ctx context.Context, cfg Config, bySize map[int64][]string) (groups []DupeGroup, complete bool) {
+	var candidates []string
+	for size, paths := range bySize {
+		if size == 0 || len(paths) < 2 {
+			continue
+		}
+		candidates = append(candidates, paths...)
+	}
+	sums, complete := hashFiles(ctx, cfg, candidates)
+
+	groups = []DupeGroup{}
+	for size, paths := range bySize {
+		if size == 0 || len(paths) < 2 {
+			continue
+		}
+		byHash := make(map[string][]string)
+		for _, path := range paths {
+			if sum, ok := sums[path]; ok {
+				byHash[sum] = append(byHash[sum], path)
+			}
+		}
+		for sum, same := range byHash {
+			if len(same) < 2 {
+				continue
+			}
+			sort.Strings(same)
+			groups = append(groups, DupeGroup{Size: size, Hash: sum, Paths: same})
+		}
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Wasted() != groups[j].Wasted() {
+			return groups[i].Wasted() > groups[j].Wasted()
+		}
+		return groups[i].Paths[0] < groups[j].Paths[0]
+	})
+	return groups, complete
+}
+
+// printManifest writes a sha256sum-style "digest  path" line for every path, sorted by path
+// It returns false if ctx was cancelled and some paths were left out
+func printManifest(ctx context.Context, w io.Writer, cfg Config, paths []string) bool {
+	paths = slices.Clone(paths)
+	sort.Strings(paths)
+	sums, complete := hashFiles(ctx, cfg, paths)
+	for _, p := range paths {
+		if sum, ok := sums[p]; ok {
+			fmt.Fprintf(w, "%s  %s\n", sum, displayPath(cfg, p))
+		}
+	}
+	return complete
+}
+
+// hashFile returns the hex-encoded SHA-256 of a file's contents
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// printDupes lists each duplicate group followed by the total reclaimable size
+func printDupes(w io.Writer, cfg Config, groups []DupeGroup) {
+	if len(groups) == 0 {
+		fmt.Fprintln(w, "No duplicate files found.")
+		return
+	}
+
+	var wasted int64
+	fmt.Fprintln(w, "Duplicate files:")
+	for _, g := range groups {
+		fmt.Fprintf(w, "%d copies of %s, %s reclaimable\n", len(g.Paths), formatSize(cfg, g.Size), formatSize(cfg, g.Wasted()))
+		for _, p := range g.Paths {
+			fmt.Fprintf(w, "    %s\n", displayPath(cfg, p))
+		}
+		wasted += g.Wasted()
+	}
+	fmt.Fprintf(w, "Total reclaimable: %s in %d groups\n", formatSize(cfg, wasted), len(groups))
+}
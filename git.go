@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// gitChangedFiles lists the files changed in a commit range below dir
+// Paths are joined onto dir; deleted files are left out since they cannot be stat'ed
+func gitChangedFiles(dir, commitRange string) ([]string, error) {
+	check := exec.Command("git", "-C", dir, "rev-parse", "--is-inside-work-tree")
+	if out, err := check.Output(); err != nil || strings.TrimSpace(string(out)) != "true" {
+		return nil, fmt.Errorf("%s is not inside a git repository", dir)
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", "-C", dir, "diff", "--name-only", "--relative", "--diff-filter=d", commitRange, "--")
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git diff %s: %v: %s", commitRange, err, strings.TrimSpace(stderr.String()))
+	}
+
+	var paths []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			paths = append(paths, filepath.Join(dir, filepath.FromSlash(line)))
+		}
+	}
+	return paths, nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreRule is a single parsed .gitignore line
+type ignoreRule struct {
+	pattern  string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// gitIgnore holds the .gitignore rules loaded while walking, keyed by directory
+// It is only touched by the walking goroutine, so it needs no locking
+type gitIgnore struct {
+	root  string
+	rules map[string][]ignoreRule
+}
+
+// newGitIgnore returns an empty rule set for a walk rooted at root
+func newGitIgnore(root string) *gitIgnore {
+	return &gitIgnore{root: filepath.Clean(root), rules: make(map[string][]ignoreRule)}
+}
+
+// load reads dir/.gitignore if it exists
+// Supported syntax is a subset of git's: blank lines and # comments are skipped,
+// a leading ! negates, a trailing / only matches directories, a leading or
+// middle / anchors the pattern to dir, and a leading **/ is ignored. Other
+// patterns match the base name at any depth using shell glob rules
+func (g *gitIgnore) load(dir string) error {
+	f, err := os.Open(filepath.Join(dir, ".gitignore"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var rules []ignoreRule
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), " \r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var r ignoreRule
+		if rest, ok := strings.CutPrefix(line, "!"); ok {
+			r.negate = true
+			line = rest
+		}
+		if rest, ok := strings.CutSuffix(line, "/"); ok {
+			r.dirOnly = true
+			line = rest
+		}
+		line = strings.TrimPrefix(line, "**/")
+		if strings.Contains(line, "/") {
+			r.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if _, err := path.Match(line, ""); err != nil || line == "" {
+			// Skip patterns filepath.Match cannot handle rather than failing the walk
+			continue
+		}
+		r.pattern = line
+		rules = append(rules, r)
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+
+	if len(rules) > 0 {
+		g.rules[filepath.Clean(dir)] = rules
+	}
+	return nil
+}
+
+// ignored reports whether p is excluded by the rules of its ancestor directories
+// Rules from deeper directories are applied last and the last match wins
+func (g *gitIgnore) ignored(p string, isDir bool) bool {
+	p = filepath.Clean(p)
+	var chain []string
+	for dir := filepath.Dir(p); ; dir = filepath.Dir(dir) {
+		chain = append(chain, dir)
+		if dir == g.root || dir == filepath.Dir(dir) {
+			break
+		}
+	}
+
+	ignored := false
+	for i := len(chain) - 1; i >= 0; i-- {
+		rules := g.rules[chain[i]]
+		if len(rules) == 0 {
+			continue
+		}
+		rel, err := filepath.Rel(chain[i], p)
+		if err != nil {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		for _, r := range rules {
+			if r.matches(rel, isDir) {
+				ignored = !r.negate
+			}
+		}
+	}
+	return ignored
+}
+
+// matches reports whether rel (relative to the rule's .gitignore) matches the rule
+func (r ignoreRule) matches(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	target := rel
+	if !r.anchored {
+		target = path.Base(rel)
+	}
+	ok, _ := path.Match(r.pattern, target)
+	return ok
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// isGlob reports whether a positional argument should be expanded as a file glob
+// An existing path is always scanned as is, even if its name contains metacharacters
+func isGlob(arg string) bool {
+	if !strings.ContainsAny(arg, "*?[") {
+		return false
+	}
+	_, err := os.Lstat(arg)
+	return err != nil
+}
+
+// splitGlob returns the directory prefix of pattern that has no metacharacters
+// and the remaining slash-separated pattern segments
+func splitGlob(pattern string) (string, []string) {
+	segs := strings.Split(filepath.ToSlash(pattern), "/")
+	i := 0
+	for i < len(segs)-1 && !strings.ContainsAny(segs[i], "*?[") {
+		i++
+	}
+	base := strings.Join(segs[:i], "/")
+	switch {
+	case base == "" && strings.HasPrefix(pattern, "/"):
+		base = "/"
+	case base == "":
+		base = "."
+	}
+	return filepath.FromSlash(base), segs[i:]
+}
+
+// expandGlob returns the files matching pattern, where "**" matches any
+// number of directories (including none) and other segments use path.Match
+// Directories are pruned as walkDir would below the glob base, and
+// unreadable ones are reported and skipped
+func expandGlob(cfg Config, pattern string) (string, []string) {
+	base, segs := splitGlob(pattern)
+	cfg.Dir = base
+	var ignore *gitIgnore
+	if cfg.GitIgnore {
+		ignore = newGitIgnore(base)
+	}
+	var matches []string
+	filepath.WalkDir(base, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			warnSkip(cfg, p, err)
+			return nil
+		}
+		if d.IsDir() {
+			if reason := dirReason(cfg, p, d.Name()); reason != "" {
+				tracef(cfg, "skip     %s (%s)", p, reason)
+				return filepath.SkipDir
+			}
+			if ignore != nil {
+				if p != base && ignore.ignored(p, true) {
+					tracef(cfg, "skip     %s (matches .gitignore)", p)
+					return filepath.SkipDir
+				}
+				if err := ignore.load(p); err != nil {
+					warnSkip(cfg, ".gitignore in "+p, err)
+				}
+			}
+			return nil
+		}
+		if ignore != nil && ignore.ignored(p, false) {
+			tracef(cfg, "skip     %s (matches .gitignore)", p)
+			return nil
+		}
+		rel, err := filepath.Rel(base, p)
+		if err != nil {
+			return nil
+		}
+		if matchSegments(segs, strings.Split(filepath.ToSlash(rel), "/")) {
+			matches = append(matches, p)
+		}
+		return nil
+	})
+	return base, matches
+}
+
+// matchSegments matches path segments against pattern segments
+func matchSegments(pat, segs []string) bool {
+	if len(pat) == 0 {
+		return len(segs) == 0
+	}
+	if pat[0] == "**" {
+		// Let ** swallow zero or more leading segments
+		for i := 0; i <= len(segs); i++ {
+			if matchSegments(pat[1:], segs[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segs) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pat[0], segs[0]); !ok {
+		return false
+	}
+	return matchSegments(pat[1:], segs[1:])
+}
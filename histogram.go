@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// defaultBuckets are the --histogram boundaries used when --buckets is not given
+var (
+	defaultBuckets      = []int64{1 << 10, 1 << 20, 100 << 20}
+	defaultBucketLabels = []string{"1K", "1M", "100M"}
+)
+
+// bucketIndex returns the histogram bucket for size
+// Bucket i holds sizes below bounds[i]; the last bucket holds everything else
+func bucketIndex(bounds []int64, size int64) int {
+	return sort.Search(len(bounds), func(i int) bool { return size < bounds[i] })
+}
+
+// parseBuckets turns "1K,1M,100M" into ascending boundaries and their labels
+func parseBuckets(s string) ([]int64, []string, error) {
+	var bounds []int64
+	var labels []string
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		n, err := parseSize(part)
+		if err != nil {
+			return nil, nil, err
+		}
+		if n <= 0 || (len(bounds) > 0 && n <= bounds[len(bounds)-1]) {
+			return nil, nil, fmt.Errorf("boundaries must be positive and ascending, got %q", s)
+		}
+		bounds = append(bounds, n)
+		labels = append(labels, part)
+	}
+	return bounds, labels, nil
+}
+
+// printHistogram prints one row of bucket counts per key, in name order
+func printHistogram(w io.Writer, cfg Config, hist map[string][]int) {
+	labels := cfg.BucketLabels
+	header := fmt.Sprintf("%-10s", "Size histogram:")
+	for i := 0; i <= len(labels); i++ {
+		var col string
+		switch i {
+		case 0:
+			col = "<" + labels[0]
+		case len(labels):
+			col = ">=" + labels[i-1]
+		default:
+			col = labels[i-1] + "-" + labels[i]
+		}
+		header += fmt.Sprintf(" %10s", col)
+	}
+	fmt.Fprintln(w, header)
+
+	keys := make([]string, 0, len(hist))
+	for k := range hist {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		line := fmt.Sprintf("    %-11s", k)
+		for _, n := range hist[k] {
+			line += fmt.Sprintf(" %10d", n)
+		}
+		fmt.Fprintln(w, line)
+	}
+}
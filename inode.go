@@ -0,0 +1,39 @@
+package main
+
+import (
+	"io/fs"
+	"sync"
+)
+
+// fileID identifies a file independently of its path
+type fileID struct {
+	dev, ino uint64
+}
+
+// inodeSet remembers the hard-linked files already counted by --dedupe-inodes
+// It is shared by all walk workers, hence the mutex
+type inodeSet struct {
+	mu   sync.Mutex
+	seen map[fileID]struct{}
+}
+
+// newInodeSet returns an empty inodeSet
+func newInodeSet() *inodeSet {
+	return &inodeSet{seen: make(map[fileID]struct{})}
+}
+
+// first reports whether info is the first link to its inode seen so far
+// Files with a single link, or without inode information, are always first
+func (s *inodeSet) first(info fs.FileInfo) bool {
+	id, links, ok := statFileID(info)
+	if !ok || links < 2 {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, dup := s.seen[id]; dup {
+		return false
+	}
+	s.seen[id] = struct{}{}
+	return true
+}
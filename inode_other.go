@@ -0,0 +1,13 @@
+//go:build !unix
+
+package main
+
+import "io/fs"
+
+// inodesSupported reports whether statFileID can return inode numbers
+const inodesSupported = false
+
+// statFileID never has inode information on this platform
+func statFileID(info fs.FileInfo) (fileID, uint64, bool) {
+	return fileID{}, 0, false
+}
@@ -0,0 +1,20 @@
+//go:build unix
+
+package main
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// inodesSupported reports whether statFileID can return inode numbers
+const inodesSupported = true
+
+// statFileID returns the device and inode of info and its hard link count
+func statFileID(info fs.FileInfo) (fileID, uint64, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileID{}, 0, false
+	}
+	return fileID{dev: uint64(st.Dev), ino: st.Ino}, uint64(st.Nlink), true
+}
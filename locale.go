@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// newPrinter returns a printer for the --locale tag, or nil for the default
+// C/POSIX formatting with a period as decimal separator
+// Environment-style tags such as de_DE.UTF-8 are accepted too
+func newPrinter(tag string) (*message.Printer, error) {
+	tag, _, _ = strings.Cut(tag, ".")
+	if tag == "" || tag == "C" || tag == "POSIX" {
+		return nil, nil
+	}
+	t, err := language.Parse(strings.ReplaceAll(tag, "_", "-"))
+	if err != nil {
+		return nil, err
+	}
+	return message.NewPrinter(t), nil
+}
+
+// localef formats like fmt.Sprintf, using the --locale separators when one is set
+// Only human-oriented output goes through it; CSV, TSV, JSON and YAML never do
+func localef(cfg Config, format string, a ...any) string {
+	if cfg.printer == nil {
+		return fmt.Sprintf(format, a...)
+	}
+	return cfg.printer.Sprintf(format, a...)
+}
@@ -1,137 +1,844 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"hash/fnv"
+	"io"
 	"io/fs"
+	"math"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"regexp"
+	"runtime"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/text/message"
 )
 
 // Config holds command-line options
 // Controls which directory is scanned and how results are filtered/shown
 type Config struct {
-	Dir           string
-	Verbose       bool
-	NoBar         bool
-	ShowSize      bool
-	SizeOnly      bool
-	IncludeHidden bool
-	Human         bool
-	MinSize       int64
-	MaxSize       int64
-	Exclude       map[string]struct{}
-	ExcludeDirs   map[string]struct{}
-	BySize        bool
+	Dir             string
+	Dirs            []string
+	Verbose         bool
+	NoBar           bool
+	ShowSize        bool
+	SizeOnly        bool
+	IncludeHidden   bool
+	Human           bool
+	MinSize         int64
+	MaxSize         int64
+	Exclude         map[string]struct{}
+	Include         map[string]struct{}
+	ExcludeDirs     map[string]struct{}
+	BySize          bool
+	ByLines         bool
+	ByOwner         bool
+	MinBar          int
+	JSON            bool
+	CSV             bool
+	Top             int
+	Workers         int
+	MaxDepth        int
+	NewerThan       time.Time
+	FoldCase        bool
+	Categories      bool
+	ShowLargest     bool
+	Avg             bool
+	FollowSymlinks  bool
+	ExcludeGlobs    []string
+	OutputPath      string
+	Percentiles     bool
+	DryRun          bool
+	Color           string
+	Stdin           bool
+	Sort            string
+	Markdown        bool
+	Lines           bool
+	MinCount        int
+	FailEmpty       bool
+	ByDir           bool
+	Dupes           bool
+	GitIgnore       bool
+	SinceGit        string
+	ExcludeDirPaths []string
+	Progress        bool
+	Empty           bool
+	TSV             bool
+	Threshold       float64
+	Perms           bool
+	OlderThan       time.Time
+	NDJSON          bool
+	Absolute        bool
+	Bytes           bool
+	ExcludeRegex    []*regexp.Regexp
+	Sniff           bool
+	Oneline         bool
+	Median          bool
+	MedianPerExt    bool
+	List            bool
+	DiffDir         string
+	Tree            bool
+	ExtOnly         bool
+	NoColor         bool
+	CountDirs       bool
+	CountSymlinks   bool
+	BarWidth        int
+	BarChar         string
+	NoExtByName     bool
+	SummaryOnly     bool
+	Relative        bool
+	RelativeTo      string
+	Timing          bool
+	HiddenOnly      bool
+	HiddenDirs      bool
+	CompoundExts    []string
+	Quiet           bool
+	SI              bool
+	CPUProfile      string
+	MemProfile      string
+	ScanArchives    bool
+	Histogram       bool
+	JSONPretty      bool
+	DedupeInodes    bool
+	FindEmptyDirs   bool
+	OtherLabel      string
+	DepthReport     bool
+	Thousands       bool
+	MaxFiles        int64
+	Age             bool
+	AgePerExt       bool
+	Reverse         bool
+	SizeBudget      int64
+	YAML            bool
+	RelativeToAll   bool
+	CategorizeCmd   string
+	CategorizeFile  bool
+	NoOther         bool
+	Manifest        bool
+	Locale          string
+	CachePath       string
+	TUI             bool
+	Baseline        string
+	Tolerance       float64
+	AgeBuckets      bool
+	// Aliases maps extensions to the row they are counted under with --alias
+	Aliases map[string]string
+	// ColorRules maps rows to the ANSI color set with --color-rule
+	ColorRules   map[string]string
+	Buckets      []int64
+	BucketLabels []string
+
+	// skipPaths holds absolute paths the tool itself writes, such as --output or profiles,
+	// so a re-run never counts its own report
+	skipPaths map[string]struct{}
+
+	// scan is the state of the logical scan in progress, fresh for each one
+	scan *scanState
+
+	// owners resolves file owners to user names when --by-owner is set
+	owners *ownerNames
+
+	// categorizer runs --categorize-cmd, shared by all workers
+	categorizer *categorizer
+
+	// cache remembers sizes, mtimes and hashes between runs with --cache
+	cache *scanCache
+
+	// now is the reference time --newer-than, --older-than and --age-buckets
+	// measure ages from, taken once so all of them agree
+	now time.Time
+
+	// baseline holds the rows of the --baseline report
+	baseline *ScanResult
+
+	// printer formats numbers for --locale, nil for the default formatting
+	printer *message.Printer
+
+	// allTotal and allBytes are the unfiltered totals percentages are
+	// computed against with --relative-to-all
+	allTotal int
+	allBytes int64
+
+	// totalLines is the line count percentages are computed against with --sort lines
+	totalLines int64
+}
+
+// skipPath registers path so scans leave it out of the statistics
+func (c *Config) skipPath(path string) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return
+	}
+	if c.skipPaths == nil {
+		c.skipPaths = make(map[string]struct{})
+	}
+	c.skipPaths[abs] = struct{}{}
+}
+
+// machineOutput reports whether a machine-readable format was requested
+// In these modes no human-oriented text may be mixed into stdout
+// --oneline counts too since prompts and status bars embed the line verbatim
+func (c Config) machineOutput() bool {
+	return c.JSON || c.YAML || c.NDJSON || c.CSV || c.TSV || c.Oneline
 }
 
 // FileStat stores aggregated file statistics for an extension
 // Ext = file extension, Count = number of files, Size = cumulative bytes
+// Largest/LargestPath describe the biggest single file in the group
+// Lines is the newline count of text files, only filled with --lines
 type FileStat struct {
-	Ext   string
-	Count int
-	Size  int64
+	Ext         string
+	Count       int
+	Size        int64
+	Largest     int64
+	LargestPath string
+	Lines       int64
 }
 
 // help string for CLI usage
 var helpString = `
-Usage: file-stats [options] [directory]
+Usage: file-stats [options] [directory|glob...]
+
+A glob such as 'src/**/*.go' counts just the matching files.
 
 Options:
     --verbose           Show all file types, including those <1%.
+    --bar-width <n>     Bar length in columns (default 40).
+    --min-bar <n>       Draw at least n bar characters for any non-zero share.
+    --bar-char <str>    Character(s) used to fill bars (default █).
     --nobar             Suppress bar chart output, print percentages only.
+    --absolute          Print raw counts and sizes instead of percentages and bars.
+    --bytes             Print sizes as exact byte counts instead of KB/MB/GB.
     --size              Print total directory size.
     --sizeonly          Only print directory size and exit.
-    --include-hidden    Include hidden files in stats.
+    --timing            Print scan time and throughput to stderr.
+    --relative-to [base]
+                        Print --list, --show-largest and --dupes paths relative
+                        to base (default: the scanned directory).
+    --summary-only      Only print the totals line (files, size, average).
+    --include-hidden    Include hidden files and directories in stats.
+    --cpuprofile <file> Write a CPU profile of the run to file (go tool pprof).
+    --memprofile <file> Write a heap profile to file when the run ends.
+    --age               Print the oldest and newest modification times (RFC3339).
+    --age-per-ext       Also print them for each row; implies --age.
+    --age-buckets       Print file counts and sizes modified today, this week,
+                        this month, this year and earlier.
+    --max-files <n>     Stop scanning after n matching files and print partial results.
+    --thousands         Group digits of counts with commas (1,234,567).
+    --depth-report      Print the number of files at each directory depth.
+    --histogram         Print per-row counts of files in each size bucket.
+    --buckets <sizes>   Histogram boundaries (default 1K,1M,100M).
+    --scan-archives     Count the entries of .zip files instead of the archives.
+    --locale <tag>      Format sizes and percentages for a locale such as de-DE.
+    --si                Use powers of 1000 (kB, MB, GB) instead of 1024 for sizes.
+    -q, --quiet         Do not print warnings about unreadable files or directories.
+    --alias <from=to>   Count extension from under the name to (e.g. jpg=jpeg).
+    --compound-ext[=list]
+                        Group double extensions such as tar.gz as one (default
+                        list: tar.gz, tar.bz2, tar.xz).
+    --hidden-dirs       Descend into hidden directories even without --include-hidden.
+    --hidden-only       Only count hidden files (implies --include-hidden).
     --human             Round percentages to whole numbers.
-    --minsize <bytes>   Only include files >= this size.
-    --maxsize <bytes>   Only include files <= this size.
+    --minsize <size>    Only include files >= this size (e.g. 4096, 500KB, 1.5MB).
+    --maxsize <size>    Only include files <= this size.
     --exclude <exts>    Comma-separated list of extensions to exclude.
+    --include <exts>    Comma-separated list of extensions to count; all others
+                        are skipped. An empty entry selects files without one.
+    --only-ext <ext>    Only count files with this extension (repeatable).
     --excludedir <dirs> Comma-separated list of directory names to exclude.
-    --bysize            Sort results by file size instead of count.
+    --exclude-regex <re> Skip files whose path relative to the target matches re
+                        (repeatable).
+    --excludedir-path <p> Comma-separated globs matched against directory paths
+                        relative to the target (e.g. build/cache).
+    --bysize            Sort results by file size instead of count (same as --sort size).
+    --sort, --by <key>  Order rows by count (default), size, lines or name.
+    -r, --reverse       Reverse the row order; "other" stays last.
+    --json              Print results as JSON.
+    --dedupe-inodes     Count hard-linked files once (Unix only).
+    --json-pretty       Indent --json output for reading.
+    --categorize-cmd <cmd>
+                        Group files by the first output line of cmd run with
+                        the file path as its last argument (once per extension).
+    --categorize-per-file
+                        Run --categorize-cmd for every file instead.
+    --relative-to-all   Compute percentages against all files, ignoring the size,
+                        age and extension filters (walks the tree twice).
+    --yaml              Print results as YAML with the same fields as --json.
+    --ndjson            Print one JSON object per line, ending with a summary line.
+    --csv               Print results as CSV (ext,count,size,percent).
+    --tsv               Print results as tab-separated values with the same columns.
+    --lines             Count lines of text files and show them per row.
+    --markdown          Print results as a GitHub-flavored Markdown table.
+    --top <n>           Only show the n largest entries, folding the rest into "other".
+    --threshold <pct>   Fold entries below pct percent into "other" (default 1).
+    --min-count <n>     Fold extensions with fewer than n files into "other".
+    --no-other          Drop entries below the threshold instead of folding them
+                        into "other"; percentages then sum to less than 100.
+    --other-label <s>   Name of the folded bucket (default "other").
+    --workers <n>       Number of goroutines used to stat and hash files (default: CPU count).
+    --maxdepth <n>      Do not descend more than n directories (0 = target dir only).
+    --newer-than <age>  Only include files modified within age (e.g. 24h, 7d).
+    --older-than <age>  Only include files last modified more than age ago.
+    --fold-case         Group extensions case-insensitively (JPG and jpg become jpg);
+                        --exclude and --include then ignore case too.
+    --by-dir            Break down by top-level subdirectory instead of extension.
+    --by-owner          Break down by file owner instead of extension (Unix only).
+    --categories        Group extensions into code/image/document/archive/other.
+    --oneline           Print a single summary line (top 3 rows, or --top n) for
+                        shell prompts and status bars.
+    --sniff             Group files by MIME type detected from their first 512
+                        bytes instead of by extension (opens every file).
+    --show-largest      Show the largest file for each row.
+    --avg               Show the average file size for each row.
+    --follow-symlinks   Descend into symlinked directories.
+    --exclude-glob <p>  Comma-separated shell globs; matching file names are skipped.
+    --perms             Also count world-writable, setuid/setgid and executable files.
+    --empty             Also list zero-byte files per extension.
+    --percentiles       Print p50/p90/p99 file sizes (keeps every size in memory).
+    --diff <dir>        Scan dir as well and print how each row changed from the
+                        target to dir.
+    --group-noext-by-name
+                        Group files without an extension by their name
+                        (Makefile, Dockerfile) instead of [noext].
+    --count-dirs        Also report how many directories were walked and the
+                        deepest level reached.
+    --count-symlinks    Tally symlinks as live or broken instead of by extension.
+    --ext-only          Print only the sorted extensions that matched, one per line.
+    --find-empty-dirs   List directories with no matching files anywhere below them.
+    --tree              Print each directory with the size and count of everything
+                        below it; --maxdepth limits the levels shown.
+    --tui               Browse the breakdown interactively in the terminal.
+    --cache <file>      Reuse file hashes from a previous run for unchanged files.
+    --manifest          Print "sha256  path" for every matched file, sorted by path.
+    --list              Print every matched file with its size instead of a
+                        breakdown, largest first (or by path with --sort name).
+    --median            Print the median file size (keeps every size in memory).
+    --median-per-ext    Also print the median size of each row; implies --median.
+    --output <file>     Write the report to file instead of stdout.
+    --color[=mode]      Colorize bars: auto (default when given, TTY only), always, never.
+    --color-rule <ext=color>
+                        Always color ext's bar with a named color (e.g. go=green).
+    --no-color          Never colorize, even with --color=always. NO_COLOR=1 does the same.
+    --gitignore         Skip paths matched by .gitignore files found while walking.
+    --dupes             Report groups of identical files and reclaimable space.
+    --max-size-budget <size>
+                        Exit with status 3 if matched files exceed size in total.
+    --baseline <file>   Exit with status 4 if rows drift from a saved --json report.
+    --tolerance <pct>   Allowed change in count or size per row (default 0).
+    --fail-empty        Exit with status 2 when no files matched.
+    --progress          Show a running file count on stderr while scanning.
+    --stdin             Read file paths from stdin instead of walking a directory.
+    --since-git <range> Only count files changed in a git range (e.g. v1.0..v1.1).
+    -n, --dry-run       Trace which directories and files would be scanned, to stderr.
+    --config <file>     Load options from a JSON file; command-line flags win.
     --help              Show this help.
 `
 
 func main() {
-	cfg, err := parseArgs(os.Args)
+	// Ctrl-C cancels the scan so partial results can still be printed
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	go func() {
+		// Unregister after the first Ctrl-C so a second one kills the process as usual
+		<-ctx.Done()
+		stop()
+	}()
+	os.Exit(run(ctx, os.Args))
+}
+
+// run executes the tool and returns the process exit code
+// Keeping os.Exit out of here lets deferred cleanup always run
+// Cancelling ctx stops the scan early and prints what was counted so far
+func run(ctx context.Context, args []string) int {
+	cfg, err := parseArgs(args)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "Error:", err)
-		os.Exit(1)
+		return 1
 	}
 
 	if cfg == nil {
 		// --help requested, exit cleanly
-		os.Exit(0)
+		return 0
 	}
 
-	counts, sizeCounts, total, totalBytes, err := walkDir(*cfg)
+	stopProfiling, err := startProfiling(*cfg)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Error walking directory:", err)
-		os.Exit(1)
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 1
+	}
+	defer stopProfiling()
+
+	if cfg.cache != nil && !cfg.DryRun {
+		defer func() {
+			if err := cfg.cache.save(); err != nil {
+				fmt.Fprintln(os.Stderr, "Error writing cache:", err)
+			}
+		}()
+	}
+
+	out := io.Writer(os.Stdout)
+	for _, path := range []string{cfg.OutputPath, cfg.CPUProfile, cfg.MemProfile, cfg.CachePath} {
+		if path != "" {
+			cfg.skipPath(path)
+		}
+	}
+	cfg.scan = newScanState(*cfg)
+	// Catch typos up front rather than as a cryptic walk error
+	targets := []string{cfg.DiffDir}
+	if !cfg.Stdin {
+		targets = append(targets, cfg.Dirs...)
+	}
+	for _, dir := range targets {
+		if dir == "" || isGlob(dir) {
+			continue
+		}
+		if err := checkDir(dir); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			return 1
+		}
+	}
+
+	if cfg.TUI {
+		if !isTerminal(os.Stdout) {
+			fmt.Fprintln(os.Stderr, "Error: --tui needs a terminal")
+			return 1
+		}
+		if err := runTUI(*cfg); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			return 1
+		}
+		return 0
+	}
+	if cfg.OutputPath != "" && !cfg.DryRun {
+		f, err := os.Create(cfg.OutputPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error creating output file:", err)
+			return 1
+		}
+		defer f.Close()
+		out = f
+	}
+
+	stopProgress := func() {}
+	if cfg.Progress && isTerminal(os.Stderr) {
+		stopProgress = startProgress(os.Stderr, cfg.scan)
+	}
+
+	start := time.Now()
+	var res *ScanResult
+	switch {
+	case cfg.SinceGit != "":
+		paths, err := gitChangedFiles(cfg.Dir, cfg.SinceGit)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			return 1
+		}
+		res, err = scanList(ctx, *cfg, strings.NewReader(strings.Join(paths, "\n")))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error reading changed files:", err)
+			return 1
+		}
+	case cfg.Stdin:
+		res, err = scanList(ctx, *cfg, os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error reading paths from stdin:", err)
+			return 1
+		}
+	default:
+		if cfg.RelativeToAll {
+			cfg.allTotal, cfg.allBytes, err = grandTotals(ctx, *cfg)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "Error walking directory:", err)
+				return 1
+			}
+		}
+		res, err = scanTargets(ctx, *cfg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error walking directory:", err)
+			return 1
+		}
+	}
+	if cfg.Timing {
+		printTiming(os.Stderr, res, time.Since(start))
+	}
+	var other *ScanResult
+	if cfg.DiffDir != "" {
+		c := *cfg
+		c.Dir = cfg.DiffDir
+		c.scan = newScanState(c)
+		other, err = walkDir(ctx, c)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error walking directory:", err)
+			return 1
+		}
+	}
+	stopProgress()
+	if res.Truncated || (other != nil && other.Truncated) {
+		fmt.Fprintf(os.Stderr, "Stopped after %d files (--max-files); results are partial\n", cfg.MaxFiles)
+	}
+	if res.Interrupted || (other != nil && other.Interrupted) {
+		fmt.Fprintln(os.Stderr, "Interrupted; results are partial")
+	}
+	total, totalBytes := res.Total, res.TotalBytes
+	cfg.totalLines = res.TotalLines
+
+	if cfg.DryRun {
+		// Only the trace on stderr is produced
+		return 0
+	}
+
+	// exitCode is 2 when --fail-empty is set and nothing matched,
+	// 3 when the matched files exceed --max-size-budget
+	// 4 when a row drifted from the --baseline report
+	// and 130 when Ctrl-C interrupted the scan or hashing
+	exitCode := 0
+	if cfg.FailEmpty && total == 0 && totalBytes == 0 {
+		exitCode = 2
+	}
+	if cfg.SizeBudget > 0 && totalBytes > cfg.SizeBudget {
+		fmt.Fprintf(os.Stderr, "Size budget exceeded: %s is %s over the %s budget\n",
+			formatSize(*cfg, totalBytes), formatSize(*cfg, totalBytes-cfg.SizeBudget), formatSize(*cfg, cfg.SizeBudget))
+		exitCode = 3
+	}
+	if cfg.baseline != nil {
+		if drifted := baselineDrift(*cfg, cfg.baseline, statsResult(aggregateStats(*cfg, res))); len(drifted) > 0 {
+			printDrift(os.Stderr, *cfg, drifted)
+			exitCode = 4
+		}
+	}
+	if res.Interrupted || (other != nil && other.Interrupted) {
+		exitCode = 130
 	}
 
 	if cfg.SizeOnly {
-		fmt.Println(humanReadableSize(totalBytes))
-		return
+		fmt.Fprintln(out, formatSize(*cfg, totalBytes))
+		return exitCode
 	}
 
-	if cfg.ShowSize {
-		fmt.Printf("Directory size: %s\n", humanReadableSize(totalBytes))
+	if cfg.Dupes {
+		groups, complete := findDupes(ctx, *cfg, res.BySize)
+		printDupes(out, *cfg, groups)
+		if !complete && exitCode != 130 {
+			fmt.Fprintln(os.Stderr, "Interrupted; duplicate list is partial")
+			exitCode = 130
+		}
+		return exitCode
 	}
 
-	if total == 0 && totalBytes == 0 {
-		fmt.Println("No files matched criteria.")
-		return
+	if other != nil {
+		printDiff(out, *cfg, res, other)
+		return exitCode
+	}
+
+	if cfg.ExtOnly {
+		printExtOnly(out, res.Counts)
+		return exitCode
+	}
+
+	if cfg.Tree {
+		printTree(out, *cfg, res.DirTotals)
+		return exitCode
+	}
+
+	if cfg.FindEmptyDirs {
+		printEmptyDirs(out, res)
+		return exitCode
+	}
+
+	if cfg.List {
+		printList(out, *cfg, res.Files)
+		return exitCode
+	}
+
+	if cfg.Manifest {
+		if !printManifest(ctx, out, *cfg, res.Hashable) && exitCode != 130 {
+			fmt.Fprintln(os.Stderr, "Interrupted; manifest is partial")
+			exitCode = 130
+		}
+		return exitCode
+	}
+
+	if cfg.ShowSize && !cfg.machineOutput() {
+		fmt.Fprintf(out, "Directory size: %s\n", formatSize(*cfg, totalBytes))
+		if cfg.Markdown {
+			// A table may not directly follow a paragraph line
+			fmt.Fprintln(out)
+		}
+	}
+
+	if total == 0 && totalBytes == 0 && !cfg.machineOutput() {
+		fmt.Fprintln(out, "No files matched criteria.")
+		return exitCode
 	}
 
-	stats := aggregateStats(*cfg, counts, sizeCounts, total, totalBytes)
-	printStats(*cfg, stats, total, totalBytes)
+	stats := aggregateStats(*cfg, res)
+	if cfg.JSON {
+		if err := printJSON(out, *cfg, stats, total, totalBytes, res.Errors); err != nil {
+			fmt.Fprintln(os.Stderr, "Error writing JSON:", err)
+			return 1
+		}
+		return exitCode
+	}
+	if cfg.YAML {
+		if err := printYAML(out, *cfg, stats, total, totalBytes, res.Errors); err != nil {
+			fmt.Fprintln(os.Stderr, "Error writing YAML:", err)
+			return 1
+		}
+		return exitCode
+	}
+	if cfg.NDJSON {
+		if err := printNDJSON(out, *cfg, stats, total, totalBytes); err != nil {
+			fmt.Fprintln(os.Stderr, "Error writing NDJSON:", err)
+			return 1
+		}
+		return exitCode
+	}
+	if cfg.CSV {
+		if err := printCSV(out, *cfg, stats, total, totalBytes); err != nil {
+			fmt.Fprintln(os.Stderr, "Error writing CSV:", err)
+			return 1
+		}
+		return exitCode
+	}
+	if cfg.TSV {
+		if err := printTSV(out, *cfg, stats, total, totalBytes); err != nil {
+			fmt.Fprintln(os.Stderr, "Error writing TSV:", err)
+			return 1
+		}
+		return exitCode
+	}
+	if cfg.Oneline {
+		printOneline(out, *cfg, stats, len(res.Counts), totalBytes)
+		return exitCode
+	}
+	if cfg.Markdown && !cfg.SummaryOnly {
+		printMarkdown(out, *cfg, stats, total, totalBytes)
+	} else {
+		printStats(out, *cfg, stats, total, totalBytes)
+	}
+	if cfg.Percentiles {
+		printPercentiles(out, *cfg, res.Sizes)
+	}
+	if cfg.Median {
+		printMedian(out, *cfg, res.Sizes, res.KeySizes)
+	}
+	if cfg.Empty {
+		printEmpty(out, res.Empty)
+	}
+	if cfg.Perms {
+		printPerms(out, res.Perms)
+	}
+	if cfg.Histogram {
+		printHistogram(out, *cfg, res.Hist)
+	}
+	if cfg.DepthReport {
+		printDepths(out, res.Depths)
+	}
+	if cfg.Age {
+		printAges(out, res.Ages, res.KeyAges)
+	}
+	if cfg.AgeBuckets {
+		printAgeBuckets(out, *cfg, res.AgeTotals)
+	}
+	if cfg.CountDirs {
+		fmt.Fprintf(out, "Directories: %d, max depth %d\n", res.DirCount, res.DirDepth)
+	}
+	if cfg.CountSymlinks {
+		fmt.Fprintf(out, "Symlinks: %d (%d live, %d broken)\n",
+			res.Symlinks+res.BrokenSymlinks, res.Symlinks, res.BrokenSymlinks)
+	}
+	if res.Archives > 0 {
+		fmt.Fprintf(out, "Includes %d entries from %d zip archives\n", res.ArchiveEntries, res.Archives)
+	}
+	return exitCode
 }
 
 // parseArgs converts command-line args into a Config struct
-// Supports both "--flag value" and "--flag=value" forms
+// A --config file is applied first so that command-line flags override it
 func parseArgs(args []string) (*Config, error) {
 	cfg := &Config{
 		Dir:         ".",
 		Exclude:     make(map[string]struct{}),
+		Include:     make(map[string]struct{}),
+		ColorRules:  make(map[string]string),
+		Aliases:     make(map[string]string),
 		ExcludeDirs: make(map[string]struct{}),
+		Workers:     runtime.NumCPU(),
+		MaxDepth:    -1,
+		Threshold:   1,
+		BarWidth:    40,
+		BarChar:     "█",
+		OtherLabel:  "other",
+
+		Buckets:      defaultBuckets,
+		BucketLabels: defaultBucketLabels,
+
+		now: time.Now(),
+	}
+
+	if path := configPath(args[1:]); path != "" {
+		fileArgs, err := loadConfigFile(path)
+		if err != nil {
+			return nil, err
+		}
+		c, err := parseFlags(cfg, fileArgs, true)
+		if err != nil {
+			return nil, fmt.Errorf("config %s: %v", path, err)
+		}
+		if c == nil {
+			return nil, nil
+		}
+	}
+
+	// Directories given on the command line replace those from the config file
+	fileDirs := cfg.Dirs
+	cfg.Dirs = nil
+	if c, err := parseFlags(cfg, args[1:], false); c == nil || err != nil {
+		return c, err
+	}
+	if len(cfg.Dirs) == 0 {
+		cfg.Dirs = fileDirs
+	}
+	if len(cfg.Dirs) == 0 {
+		cfg.Dirs = []string{"."}
+	}
+	cfg.Dir = cfg.Dirs[0]
+	if isGlob(cfg.Dir) {
+		cfg.Dir, _ = splitGlob(cfg.Dir)
+	}
+
+	// Extension filters compare against grouping keys, which --fold-case lowercases
+	if cfg.FoldCase {
+		cfg.Exclude = lowerKeys(cfg.Exclude)
+		cfg.Include = lowerKeys(cfg.Include)
+		rules := make(map[string]string, len(cfg.ColorRules))
+		for k, v := range cfg.ColorRules {
+			rules[strings.ToLower(k)] = v
+		}
+		cfg.ColorRules = rules
+		aliases := make(map[string]string, len(cfg.Aliases))
+		for k, v := range cfg.Aliases {
+			aliases[strings.ToLower(k)] = v
+		}
+		cfg.Aliases = aliases
+	}
+
+	if cfg.DedupeInodes {
+		if !inodesSupported {
+			fmt.Fprintln(os.Stderr, "Warning: --dedupe-inodes has no effect on this platform")
+		}
+	}
+	if cfg.ByOwner {
+		if !ownersSupported {
+			return nil, fmt.Errorf("--by-owner is not available on this platform")
+		}
+		cfg.owners = newOwnerNames()
+	}
+	if cfg.Baseline != "" {
+		b, err := loadBaseline(cfg.Baseline)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --baseline value: %v", err)
+		}
+		cfg.baseline = b
+	}
+	if cfg.CachePath != "" {
+		c, err := loadCache(cfg.CachePath)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --cache value: %v", err)
+		}
+		cfg.cache = c
+	}
+	if cfg.Locale != "" {
+		p, err := newPrinter(cfg.Locale)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --locale value: %v", err)
+		}
+		cfg.printer = p
+	}
+	if cfg.CategorizeCmd != "" {
+		c, err := newCategorizer(cfg.CategorizeCmd, cfg.CategorizeFile, cfg.Quiet)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --categorize-cmd value: %v", err)
+		}
+		cfg.categorizer = c
+	}
+	if cfg.RelativeToAll && (cfg.Stdin || cfg.SinceGit != "") {
+		return nil, fmt.Errorf("--relative-to-all needs a directory walk and cannot be used with --stdin or --since-git")
+	}
+	if cfg.RelativeToAll && cfg.ByLines {
+		return nil, fmt.Errorf("--relative-to-all only counts files and bytes and cannot be used with --sort lines")
+	}
+	if cfg.TUI && (cfg.Stdin || cfg.SinceGit != "" || cfg.OutputPath != "") {
+		return nil, fmt.Errorf("--tui browses a directory walk and cannot be used with --stdin, --since-git or --output")
+	}
+	if cfg.JSONPretty && !cfg.JSON {
+		return nil, fmt.Errorf("--json-pretty requires --json")
 	}
+	if !cfg.NewerThan.IsZero() && !cfg.OlderThan.IsZero() && !cfg.OlderThan.After(cfg.NewerThan) {
+		return nil, fmt.Errorf("--older-than must be a shorter age than --newer-than, otherwise no file can match")
+	}
+	return cfg, nil
+}
+
+// lowerKeys returns a copy of set with every key lowercased
+func lowerKeys(set map[string]struct{}) map[string]struct{} {
+	out := make(map[string]struct{}, len(set))
+	for k := range set {
+		out[strings.ToLower(k)] = struct{}{}
+	}
+	return out
+}
 
-	for i := 1; i < len(args); i++ {
+// parseFlags applies flags to cfg, returning nil if --help was handled
+// Supports both "--flag value" and "--flag=value" forms
+// In strict mode (config files) unknown options are an error instead of a directory
+func parseFlags(cfg *Config, args []string, strict bool) (*Config, error) {
+	for i := 0; i < len(args); i++ {
 		arg := args[i]
 
 		// Support --key=value form
-		if strings.Contains(arg, "=") {
+		var inline string
+		hasInline := false
+		if strings.HasPrefix(arg, "--") && strings.Contains(arg, "=") {
 			parts := strings.SplitN(arg, "=", 2)
-			key := parts[0]
-			val := parts[1]
-			switch key {
-			case "--exclude":
-				for _, ext := range strings.Split(val, ",") {
-					cfg.Exclude[strings.TrimPrefix(strings.TrimSpace(ext), ".")] = struct{}{}
-				}
-			case "--excludedir":
-				for _, dir := range strings.Split(val, ",") {
-					cfg.ExcludeDirs[strings.TrimSpace(dir)] = struct{}{}
-				}
-			case "--minsize":
-				n, err := strconv.ParseInt(val, 10, 64)
-				if err != nil {
-					return nil, fmt.Errorf("invalid --minsize value: %v", err)
-				}
-				cfg.MinSize = n
-			case "--maxsize":
-				n, err := strconv.ParseInt(val, 10, 64)
-				if err != nil {
-					return nil, fmt.Errorf("invalid --maxsize value: %v", err)
-				}
-				cfg.MaxSize = n
-			default:
-				// ignore unknown --key=value
+			arg, inline, hasInline = parts[0], parts[1], true
+		}
+
+		// value returns the flag's argument from either form
+		value := func() (string, error) {
+			if hasInline {
+				return inline, nil
 			}
-			continue
+			if i+1 >= len(args) {
+				return "", fmt.Errorf("%s requires a value", arg)
+			}
+			i++
+			return args[i], nil
+		}
+
+		// intValue is value parsed as a base-10 integer
+		intValue := func() (int64, error) {
+			val, err := value()
+			if err != nil {
+				return 0, err
+			}
+			n, err := strconv.ParseInt(val, 10, 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid %s value: %v", arg, err)
+			}
+			return n, nil
 		}
 
 		switch arg {
@@ -139,6 +846,10 @@ func parseArgs(args []string) (*Config, error) {
 			cfg.Verbose = true
 		case "--nobar":
 			cfg.NoBar = true
+		case "--bytes":
+			cfg.Bytes = true
+		case "--absolute":
+			cfg.Absolute = true
 		case "--size":
 			cfg.ShowSize = true
 		case "--sizeonly":
@@ -148,200 +859,2234 @@ func parseArgs(args []string) (*Config, error) {
 		case "--human":
 			cfg.Human = true
 		case "--minsize":
-			if i+1 >= len(args) {
-				return nil, fmt.Errorf("--minsize requires a value")
+			val, err := value()
+			if err != nil {
+				return nil, err
 			}
-			i++
-			n, err := strconv.ParseInt(args[i], 10, 64)
+			n, err := parseSize(val)
 			if err != nil {
 				return nil, fmt.Errorf("invalid --minsize value: %v", err)
 			}
 			cfg.MinSize = n
 		case "--maxsize":
-			if i+1 >= len(args) {
-				return nil, fmt.Errorf("--maxsize requires a value")
+			val, err := value()
+			if err != nil {
+				return nil, err
 			}
-			i++
-			n, err := strconv.ParseInt(args[i], 10, 64)
+			n, err := parseSize(val)
 			if err != nil {
 				return nil, fmt.Errorf("invalid --maxsize value: %v", err)
 			}
 			cfg.MaxSize = n
 		case "--exclude":
-			if i+1 >= len(args) {
-				return nil, fmt.Errorf("--exclude requires a value")
+			val, err := value()
+			if err != nil {
+				return nil, err
 			}
-			i++
-			for _, ext := range strings.Split(args[i], ",") {
+			for _, ext := range strings.Split(val, ",") {
 				cfg.Exclude[strings.TrimPrefix(strings.TrimSpace(ext), ".")] = struct{}{}
 			}
+		case "--include":
+			val, err := value()
+			if err != nil {
+				return nil, err
+			}
+			for _, ext := range strings.Split(val, ",") {
+				ext = strings.TrimPrefix(strings.TrimSpace(ext), ".")
+				if ext == "" {
+					ext = "[noext]"
+				}
+				cfg.Include[ext] = struct{}{}
+			}
+		case "--only-ext":
+			// A single-extension shorthand for --include; repeated flags add up
+			val, err := value()
+			if err != nil {
+				return nil, err
+			}
+			if strings.Contains(val, ",") {
+				return nil, fmt.Errorf("--only-ext takes a single extension, use --include for lists")
+			}
+			ext := strings.TrimPrefix(strings.TrimSpace(val), ".")
+			if ext == "" {
+				ext = "[noext]"
+			}
+			cfg.Include[ext] = struct{}{}
 		case "--excludedir":
-			if i+1 >= len(args) {
-				return nil, fmt.Errorf("--excludedir requires a value")
+			val, err := value()
+			if err != nil {
+				return nil, err
 			}
-			i++
-			for _, dir := range strings.Split(args[i], ",") {
+			for _, dir := range strings.Split(val, ",") {
 				cfg.ExcludeDirs[strings.TrimSpace(dir)] = struct{}{}
 			}
+		case "--exclude-regex":
+			val, err := value()
+			if err != nil {
+				return nil, err
+			}
+			re, err := regexp.Compile(val)
+			if err != nil {
+				return nil, fmt.Errorf("invalid --exclude-regex pattern: %v", err)
+			}
+			cfg.ExcludeRegex = append(cfg.ExcludeRegex, re)
+		case "--excludedir-path":
+			val, err := value()
+			if err != nil {
+				return nil, err
+			}
+			for _, pattern := range strings.Split(val, ",") {
+				pattern = strings.Trim(filepath.ToSlash(strings.TrimSpace(pattern)), "/")
+				if _, err := filepath.Match(pattern, ""); err != nil {
+					return nil, fmt.Errorf("invalid --excludedir-path pattern %q: %v", pattern, err)
+				}
+				cfg.ExcludeDirPaths = append(cfg.ExcludeDirPaths, pattern)
+			}
 		case "--bysize":
+			// Backward-compatible alias for --sort size
 			cfg.BySize = true
-		case "--help":
-			fmt.Println(helpString)
-			return nil, nil
-		default:
-			cfg.Dir = arg
-		}
-	}
-
-	return cfg, nil
-}
-
-// walkDir scans the directory recursively and counts files by extension
-// Applies filters for hidden files, min/max size, and excluded extensions/dirs
-func walkDir(cfg Config) (map[string]int, map[string]int64, int, int64, error) {
-	counts := make(map[string]int)
-	sizeCounts := make(map[string]int64)
-	var total int
-	var totalBytes int64
-
-	err := filepath.WalkDir(cfg.Dir, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			fmt.Fprintln(os.Stderr, "Skipping", path, "due to error:", err)
-			return nil
-		}
-		if d.IsDir() {
-			if _, skip := cfg.ExcludeDirs[d.Name()]; skip {
-				return filepath.SkipDir
+			cfg.Sort = "size"
+		case "--sort", "--by":
+			val, err := value()
+			if err != nil {
+				return nil, err
 			}
-			return nil
-		}
-		if !cfg.IncludeHidden && strings.HasPrefix(d.Name(), ".") {
-			return nil
-		}
-
-		info, err := d.Info()
-		if err != nil {
-			fmt.Fprintln(os.Stderr, "Skipping", path, "due to error:", err)
-			return nil
-		}
-
-		if (cfg.MinSize > 0 && info.Size() < cfg.MinSize) || (cfg.MaxSize > 0 && info.Size() > cfg.MaxSize) {
-			return nil
-		}
-
-		ext := filepath.Ext(d.Name())
-		if ext == "" {
-			ext = "[noext]"
-		} else {
-			ext = strings.TrimPrefix(ext, ".")
-		}
-
-		if _, skip := cfg.Exclude[ext]; skip {
-			return nil
-		}
-
-		totalBytes += info.Size()
-		counts[ext]++
-		sizeCounts[ext] += info.Size()
-		total++
-		return nil
-	})
-
-	return counts, sizeCounts, total, totalBytes, err
-}
+			cfg.BySize, cfg.ByLines = false, false
+			switch val {
+			case "count", "name":
+			case "size":
+				cfg.BySize = true
+			case "lines":
+				// Line shares need line counts
+				cfg.ByLines, cfg.Lines = true, true
+			default:
+				return nil, fmt.Errorf("invalid --sort value %q (want count, size, lines or name)", val)
+			}
+			cfg.Sort = val
+		case "--json":
+			cfg.JSON = true
+		case "--ndjson":
+			cfg.NDJSON = true
+		case "--csv":
+			cfg.CSV = true
+		case "--tsv":
+			cfg.TSV = true
+		case "--lines":
+			cfg.Lines = true
+		case "--markdown":
+			cfg.Markdown = true
+		case "--fold-case":
+			cfg.FoldCase = true
+		case "--by-dir":
+			cfg.ByDir = true
+		case "--by-owner":
+			cfg.ByOwner = true
+		case "--oneline":
+			cfg.Oneline = true
+		case "--sniff":
+			cfg.Sniff = true
+		case "--categories":
+			cfg.Categories = true
+		case "--show-largest":
+			cfg.ShowLargest = true
+		case "--avg":
+			cfg.Avg = true
+		case "--follow-symlinks":
+			cfg.FollowSymlinks = true
+		case "--exclude-glob":
+			val, err := value()
+			if err != nil {
+				return nil, err
+			}
+			for _, pattern := range strings.Split(val, ",") {
+				pattern = strings.TrimSpace(pattern)
+				if _, err := filepath.Match(pattern, ""); err != nil {
+					return nil, fmt.Errorf("invalid --exclude-glob pattern %q: %v", pattern, err)
+				}
+				cfg.ExcludeGlobs = append(cfg.ExcludeGlobs, pattern)
+			}
+		case "--top":
+			n, err := intValue()
+			if err != nil {
+				return nil, err
+			}
+			if n < 0 {
+				return nil, fmt.Errorf("--top must not be negative")
+			}
+			cfg.Top = int(n)
+		case "--bar-width":
+			n, err := intValue()
+			if err != nil {
+				return nil, err
+			}
+			if n <= 0 {
+				return nil, fmt.Errorf("--bar-width must be positive")
+			}
+			cfg.BarWidth = int(n)
+		case "--min-bar":
+			n, err := intValue()
+			if err != nil {
+				return nil, err
+			}
+			if n < 0 {
+				return nil, fmt.Errorf("--min-bar must not be negative")
+			}
+			cfg.MinBar = int(n)
+		case "--bar-char":
+			val, err := value()
+			if err != nil {
+				return nil, err
+			}
+			if val == "" {
+				return nil, fmt.Errorf("--bar-char must not be empty")
+			}
+			cfg.BarChar = val
+		case "--threshold":
+			val, err := value()
+			if err != nil {
+				return nil, err
+			}
+			n, err := strconv.ParseFloat(val, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid --threshold value: %v", err)
+			}
+			if n < 0 || n > 100 {
+				return nil, fmt.Errorf("--threshold must be between 0 and 100")
+			}
+			cfg.Threshold = n
+		case "--min-count":
+			n, err := intValue()
+			if err != nil {
+				return nil, err
+			}
+			if n < 0 {
+				return nil, fmt.Errorf("--min-count must not be negative")
+			}
+			cfg.MinCount = int(n)
+		case "--workers":
+			n, err := intValue()
+			if err != nil {
+				return nil, err
+			}
+			if n < 1 {
+				return nil, fmt.Errorf("--workers must be at least 1")
+			}
+			cfg.Workers = int(n)
+		case "--maxdepth":
+			n, err := intValue()
+			if err != nil {
+				return nil, err
+			}
+			if n < 0 {
+				return nil, fmt.Errorf("--maxdepth must not be negative")
+			}
+			cfg.MaxDepth = int(n)
+		case "--newer-than":
+			val, err := value()
+			if err != nil {
+				return nil, err
+			}
+			d, err := parseAge(val)
+			if err != nil {
+				return nil, fmt.Errorf("invalid --newer-than value: %v", err)
+			}
+			cfg.NewerThan = cfg.now.Add(-d)
+		case "--older-than":
+			val, err := value()
+			if err != nil {
+				return nil, err
+			}
+			d, err := parseAge(val)
+			if err != nil {
+				return nil, fmt.Errorf("invalid --older-than value: %v", err)
+			}
+			cfg.OlderThan = cfg.now.Add(-d)
+		case "--color":
+			// Bare --color means auto; --color=always forces it when piped
+			mode := "auto"
+			if hasInline {
+				mode = inline
+			}
+			switch mode {
+			case "auto", "always", "never":
+				cfg.Color = mode
+			default:
+				return nil, fmt.Errorf("invalid --color value %q (want auto, always or never)", mode)
+			}
+		case "--no-color":
+			cfg.NoColor = true
+		case "--color-rule":
+			val, err := value()
+			if err != nil {
+				return nil, err
+			}
+			ext, name, ok := strings.Cut(val, "=")
+			ext = strings.TrimPrefix(strings.TrimSpace(ext), ".")
+			if !ok || ext == "" {
+				return nil, fmt.Errorf("invalid --color-rule value %q (want ext=color)", val)
+			}
+			code, ok := colorNames[strings.ToLower(strings.TrimSpace(name))]
+			if !ok {
+				return nil, fmt.Errorf("invalid --color-rule color %q (want one of %s)", name, strings.Join(colorNameList(), ", "))
+			}
+			cfg.ColorRules[ext] = code
+		case "--gitignore":
+			cfg.GitIgnore = true
+		case "--dupes":
+			cfg.Dupes = true
+		case "--fail-empty":
+			cfg.FailEmpty = true
+		case "--since-git":
+			val, err := value()
+			if err != nil {
+				return nil, err
+			}
+			cfg.SinceGit = val
+		case "--progress":
+			cfg.Progress = true
+		case "--stdin":
+			cfg.Stdin = true
+		case "-n", "--dry-run":
+			cfg.DryRun = true
+		case "--perms":
+			cfg.Perms = true
+		case "--empty":
+			cfg.Empty = true
+		case "--percentiles":
+			cfg.Percentiles = true
+		case "--diff":
+			val, err := value()
+			if err != nil {
+				return nil, err
+			}
+			cfg.DiffDir = val
+		case "--cpuprofile":
+			val, err := value()
+			if err != nil {
+				return nil, err
+			}
+			cfg.CPUProfile = val
+		case "--memprofile":
+			val, err := value()
+			if err != nil {
+				return nil, err
+			}
+			cfg.MemProfile = val
+		case "--max-size-budget":
+			val, err := value()
+			if err != nil {
+				return nil, err
+			}
+			n, err := parseSize(val)
+			if err != nil {
+				return nil, fmt.Errorf("invalid --max-size-budget value: %v", err)
+			}
+			cfg.SizeBudget = n
+		case "-r", "--reverse":
+			cfg.Reverse = true
+		case "--age":
+			cfg.Age = true
+		case "--age-per-ext":
+			cfg.Age = true
+			cfg.AgePerExt = true
+		case "--max-files":
+			n, err := intValue()
+			if err != nil {
+				return nil, err
+			}
+			if n < 0 {
+				return nil, fmt.Errorf("--max-files must not be negative")
+			}
+			cfg.MaxFiles = n
+		case "--thousands":
+			cfg.Thousands = true
+		case "--depth-report":
+			cfg.DepthReport = true
+		case "--other-label":
+			val, err := value()
+			if err != nil {
+				return nil, err
+			}
+			if val == "" {
+				return nil, fmt.Errorf("--other-label must not be empty")
+			}
+			cfg.OtherLabel = val
+		case "--find-empty-dirs":
+			cfg.FindEmptyDirs = true
+		case "--dedupe-inodes":
+			cfg.DedupeInodes = true
+		case "--categorize-cmd":
+			val, err := value()
+			if err != nil {
+				return nil, err
+			}
+			cfg.CategorizeCmd = val
+		case "--baseline":
+			val, err := value()
+			if err != nil {
+				return nil, err
+			}
+			cfg.Baseline = val
+		case "--tolerance":
+			val, err := value()
+			if err != nil {
+				return nil, err
+			}
+			n, err := strconv.ParseFloat(val, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid --tolerance value: %v", err)
+			}
+			if n < 0 {
+				return nil, fmt.Errorf("--tolerance must not be negative")
+			}
+			cfg.Tolerance = n
+		case "--alias":
+			val, err := value()
+			if err != nil {
+				return nil, err
+			}
+			from, to, ok := strings.Cut(val, "=")
+			from = strings.TrimPrefix(strings.TrimSpace(from), ".")
+			to = strings.TrimPrefix(strings.TrimSpace(to), ".")
+			if !ok || from == "" || to == "" {
+				return nil, fmt.Errorf("invalid --alias value %q (want from=to)", val)
+			}
+			cfg.Aliases[from] = to
+		case "--age-buckets":
+			cfg.AgeBuckets = true
+		case "--tui":
+			cfg.TUI = true
+		case "--cache":
+			val, err := value()
+			if err != nil {
+				return nil, err
+			}
+			cfg.CachePath = val
+		case "--locale":
+			val, err := value()
+			if err != nil {
+				return nil, err
+			}
+			cfg.Locale = val
+		case "--manifest":
+			cfg.Manifest = true
+		case "--no-other":
+			cfg.NoOther = true
+		case "--categorize-per-file":
+			cfg.CategorizeFile = true
+		case "--relative-to-all":
+			cfg.RelativeToAll = true
+		case "--yaml":
+			cfg.YAML = true
+		case "--json-pretty":
+			cfg.JSONPretty = true
+		case "--histogram":
+			cfg.Histogram = true
+		case "--buckets":
+			val, err := value()
+			if err != nil {
+				return nil, err
+			}
+			bounds, labels, err := parseBuckets(val)
+			if err != nil {
+				return nil, fmt.Errorf("invalid --buckets value: %v", err)
+			}
+			cfg.Buckets, cfg.BucketLabels = bounds, labels
+		case "--scan-archives":
+			cfg.ScanArchives = true
+		case "--si":
+			cfg.SI = true
+		case "-q", "--quiet":
+			cfg.Quiet = true
+		case "--compound-ext":
+			// Bare --compound-ext uses the built-in list; a value replaces it
+			cfg.CompoundExts = defaultCompoundExts
+			if hasInline {
+				cfg.CompoundExts = nil
+				for _, ext := range strings.Split(inline, ",") {
+					if ext = strings.Trim(strings.TrimSpace(ext), "."); ext != "" {
+						cfg.CompoundExts = append(cfg.CompoundExts, ext)
+					}
+				}
+			}
+		case "--hidden-dirs":
+			cfg.HiddenDirs = true
+		case "--hidden-only":
+			cfg.HiddenOnly = true
+			cfg.IncludeHidden = true
+		case "--timing":
+			cfg.Timing = true
+		case "--relative-to":
+			// Bare --relative-to uses the scanned directory as the base
+			// A following non-flag argument is the base, not a directory to scan
+			cfg.Relative = true
+			cfg.RelativeTo = ""
+			switch {
+			case hasInline:
+				cfg.RelativeTo = inline
+			case i+1 < len(args) && !strings.HasPrefix(args[i+1], "-"):
+				i++
+				cfg.RelativeTo = args[i]
+			}
+		case "--summary-only":
+			cfg.SummaryOnly = true
+		case "--group-noext-by-name":
+			cfg.NoExtByName = true
+		case "--count-dirs":
+			cfg.CountDirs = true
+		case "--count-symlinks":
+			cfg.CountSymlinks = true
+		case "--ext-only":
+			cfg.ExtOnly = true
+		case "--tree":
+			cfg.Tree = true
+		case "--list":
+			cfg.List = true
+		case "--median":
+			cfg.Median = true
+		case "--median-per-ext":
+			cfg.Median = true
+			cfg.MedianPerExt = true
+		case "--output":
+			val, err := value()
+			if err != nil {
+				return nil, err
+			}
+			cfg.OutputPath = val
+		case "--config":
+			// Already loaded by parseArgs before any other flag
+			if strict {
+				return nil, fmt.Errorf("--config cannot be used inside a config file")
+			}
+			if _, err := value(); err != nil {
+				return nil, err
+			}
+		case "--help":
+			fmt.Println(helpString)
+			return nil, nil
+		default:
+			if strict && strings.HasPrefix(arg, "-") {
+				return nil, fmt.Errorf("unknown option %q", strings.TrimLeft(arg, "-"))
+			}
+			if hasInline {
+				// ignore unknown --key=value
+				continue
+			}
+			cfg.Dirs = append(cfg.Dirs, arg)
+		}
+	}
+
+	return cfg, nil
+}
+
+// parseAge parses a Go duration ("36h", "90m") or a day count ("7d")
+// Negative ages are rejected since they would point into the future
+func parseAge(s string) (time.Duration, error) {
+	var d time.Duration
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.ParseFloat(days, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid day count %q", s)
+		}
+		d = time.Duration(n * float64(24*time.Hour))
+	} else {
+		var err error
+		d, err = time.ParseDuration(s)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("age %q must not be negative", s)
+	}
+	return d, nil
+}
+
+// ScanResult accumulates per-extension statistics gathered by a scan
+// Each walker goroutine fills its own ScanResult which are merged at the end
+type ScanResult struct {
+	Counts     map[string]int
+	SizeCounts map[string]int64
+	Largest    map[string]fileRef
+	Lines      map[string]int64
+	// TotalLines is the sum of Lines, only collected with --lines
+	TotalLines int64
+	Empty      map[string]int
+	Perms      PermCounts
+	Total      int
+	TotalBytes int64
+
+	// Sizes holds every counted file size, only collected with --percentiles or --median
+	Sizes []int64
+
+	// KeySizes holds the file sizes of each row, only collected with --median-per-ext
+	KeySizes map[string][]int64
+
+	// BySize groups counted regular files by size, only collected with --dupes
+	BySize map[int64][]string
+
+	// Files holds every counted file, only collected with --list
+	Files []fileRef
+
+	// Hashable holds the regular files --manifest hashes, including each
+	// opened archive in place of its entries
+	Hashable []string
+
+	// DirTotals holds per-directory subtotals, only collected with --tree or --find-empty-dirs
+	DirTotals map[string]dirTotal
+
+	// WalkedDirs lists every directory descended into, only collected with --find-empty-dirs
+	WalkedDirs []string
+
+	// Ages and KeyAges hold modification time ranges overall and per row,
+	// only collected with --age and --age-per-ext
+	Ages    ageRange
+	KeyAges map[string]ageRange
+
+	// AgeTotals holds the files in each ageBuckets class, only collected with --age-buckets
+	AgeTotals []dirTotal
+
+	// Depths counts files by directory depth below the target (0 = directly in it),
+	// only collected with --depth-report
+	Depths map[int]int
+
+	// Hist holds per-row counts for each --buckets range, only collected with --histogram
+	Hist map[string][]int
+
+	// Archives and ArchiveEntries count the zip files opened by --scan-archives
+	// and the entries counted from them
+	Archives       int
+	ArchiveEntries int
+
+	// Truncated is set when --max-files stopped the scan early
+	Truncated bool
+
+	// Interrupted is set when Ctrl-C stopped the scan early
+	Interrupted bool
+
+	// Errors lists the entries skipped because they could not be read
+	Errors []ScanError
+
+	// DirCount and DirDepth are the number of walked directories below the
+	// target and the deepest level reached, only collected with --count-dirs
+	DirCount int
+	DirDepth int
+
+	// Symlinks and BrokenSymlinks count links whose target exists and links
+	// whose target is missing, only collected with --count-symlinks
+	Symlinks       int
+	BrokenSymlinks int
+}
+
+// addSymlink tallies the symlink at path as live or broken
+// Only a missing target makes a link broken; other stat errors are reported
+func (r *ScanResult) addSymlink(cfg Config, path string) {
+	_, err := os.Stat(path)
+	switch {
+	case err == nil:
+		tracef(cfg, "symlink  %s", path)
+		r.Symlinks++
+	case errors.Is(err, fs.ErrNotExist):
+		tracef(cfg, "symlink  %s (broken)", path)
+		r.BrokenSymlinks++
+	default:
+		r.skip(cfg, path, err)
+	}
+}
+
+// fileRef identifies a single file by path and size
+type fileRef struct {
+	Path string
+	Size int64
+}
+
+// beats reports whether f should replace o as the largest file
+// Ties are broken by path so the result does not depend on walk order
+func (f fileRef) beats(o fileRef) bool {
+	if f.Size != o.Size {
+		return f.Size > o.Size
+	}
+	return f.Path < o.Path
+}
+
+// newScanResult returns an empty ScanResult with initialized maps
+func newScanResult() *ScanResult {
+	return &ScanResult{
+		Counts:     make(map[string]int),
+		SizeCounts: make(map[string]int64),
+		Largest:    make(map[string]fileRef),
+		Lines:      make(map[string]int64),
+		Empty:      make(map[string]int),
+		BySize:     make(map[int64][]string),
+		KeySizes:   make(map[string][]int64),
+		DirTotals:  make(map[string]dirTotal),
+		Hist:       make(map[string][]int),
+		Depths:     make(map[int]int),
+		KeyAges:    make(map[string]ageRange),
+	}
+}
+
+// trackLargest records f as the largest file for ext if it beats the current one
+func (r *ScanResult) trackLargest(ext string, f fileRef) {
+	if cur, ok := r.Largest[ext]; !ok || f.beats(cur) {
+		r.Largest[ext] = f
+	}
+}
+
+// classify returns the grouping key for a file and, if a filter rejects it, the reason
+// An empty reason means the file should be counted
+func classify(cfg Config, path string, info fs.FileInfo) (ext, reason string) {
+	if reason := pathReason(cfg, path, info.Name()); reason != "" {
+		return "", reason
+	}
+	if cfg.MinSize > 0 && info.Size() < cfg.MinSize {
+		return "", "smaller than --minsize"
+	}
+	if cfg.MaxSize > 0 && info.Size() > cfg.MaxSize {
+		return "", "larger than --maxsize"
+	}
+
+	if !cfg.NewerThan.IsZero() && info.ModTime().Before(cfg.NewerThan) {
+		return "", "older than --newer-than"
+	}
+	if !cfg.OlderThan.IsZero() && info.ModTime().After(cfg.OlderThan) {
+		return "", "newer than --older-than"
+	}
+
+	ext = fileExt(cfg, info.Name())
+	if _, skip := cfg.Exclude[ext]; skip {
+		return ext, "excluded extension"
+	}
+	if len(cfg.Include) > 0 {
+		if _, ok := cfg.Include[ext]; !ok {
+			return ext, "extension not in --include"
+		}
+	}
+	return ext, ""
+}
+
+// pathReason returns why the file at path, named name, is rejected by the
+// self-skip, --exclude-glob or --exclude-regex filters, or "" if none applies
+func pathReason(cfg Config, path, name string) string {
+	if len(cfg.skipPaths) > 0 {
+		if abs, err := filepath.Abs(path); err == nil {
+			if _, skip := cfg.skipPaths[abs]; skip {
+				return "written by dstat"
+			}
+		}
+	}
+
+	for _, pattern := range cfg.ExcludeGlobs {
+		// Patterns are validated in parseArgs, so errors cannot occur here
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return "matches --exclude-glob " + pattern
+		}
+	}
+
+	if len(cfg.ExcludeRegex) > 0 {
+		rel, err := filepath.Rel(cfg.Dir, path)
+		if err != nil {
+			rel = path
+		}
+		rel = filepath.ToSlash(rel)
+		for _, re := range cfg.ExcludeRegex {
+			if re.MatchString(rel) {
+				return "matches --exclude-regex " + re.String()
+			}
+		}
+	}
+	return ""
+}
+
+// add records a single file if it passes the size, time, glob and extension filters
+// With --dry-run the decision is traced instead of recorded
+func (r *ScanResult) add(cfg Config, path string, info fs.FileInfo) {
+	if cfg.ScanArchives && isZip(path) {
+		if reason := archiveReason(cfg, path, info); reason != "" {
+			tracef(cfg, "skip     %s (%s)", path, reason)
+			return
+		}
+		if r.addZip(cfg, path) {
+			return
+		}
+	}
+	ext, reason := classify(cfg, path, info)
+	if reason == "" && cfg.scan.inodes != nil && !cfg.scan.inodes.first(info) {
+		reason = "hard link already counted"
+	}
+	if reason != "" {
+		tracef(cfg, "skip     %s (%s)", path, reason)
+		return
+	}
+	if cfg.DryRun {
+		tracef(cfg, "count    %s (ext %s)", path, ext)
+		return
+	}
+
+	key, err := groupKey(cfg, path, info, ext)
+	if err != nil {
+		r.skip(cfg, path, err)
+		return
+	}
+	if n := cfg.scan.counted.Add(1); cfg.MaxFiles > 0 && n > cfg.MaxFiles {
+		r.Truncated = true
+		return
+	}
+	if cfg.cache != nil {
+		cfg.cache.observe(path, info)
+	}
+	r.TotalBytes += info.Size()
+	r.Counts[key]++
+	r.SizeCounts[key] += info.Size()
+	r.trackLargest(key, fileRef{path, info.Size()})
+	r.Total++
+	if info.Size() == 0 {
+		r.Empty[key]++
+	}
+	if cfg.Perms {
+		r.Perms.add(info.Mode())
+	}
+	if cfg.Percentiles || cfg.Median {
+		r.Sizes = append(r.Sizes, info.Size())
+	}
+	if cfg.Tree || cfg.FindEmptyDirs {
+		r.addTree(cfg.Dir, path, info.Size())
+	}
+	if cfg.Age {
+		r.Ages.add(info.ModTime())
+	}
+	if cfg.AgePerExt {
+		a := r.KeyAges[key]
+		a.add(info.ModTime())
+		r.KeyAges[key] = a
+	}
+	if cfg.AgeBuckets {
+		if r.AgeTotals == nil {
+			r.AgeTotals = make([]dirTotal, len(ageBuckets))
+		}
+		t := &r.AgeTotals[ageBucket(cfg.now, info.ModTime())]
+		t.Count++
+		t.Size += info.Size()
+	}
+	if cfg.DepthReport {
+		r.Depths[relDepth(cfg.Dir, path)]++
+	}
+	if cfg.Histogram {
+		if r.Hist[key] == nil {
+			r.Hist[key] = make([]int, len(cfg.Buckets)+1)
+		}
+		r.Hist[key][bucketIndex(cfg.Buckets, info.Size())]++
+	}
+	if cfg.List {
+		r.Files = append(r.Files, fileRef{path, info.Size()})
+	}
+	if cfg.Manifest && info.Mode().IsRegular() {
+		r.Hashable = append(r.Hashable, path)
+	}
+	if cfg.MedianPerExt {
+		r.KeySizes[key] = append(r.KeySizes[key], info.Size())
+	}
+	if cfg.Dupes && info.Mode().IsRegular() {
+		r.BySize[info.Size()] = append(r.BySize[info.Size()], path)
+	}
+	if cfg.Lines && info.Mode().IsRegular() {
+		n, err := countLines(path)
+		if err != nil {
+			warnSkip(cfg, "line count for "+path, err)
+		}
+		r.Lines[key] += n
+		r.TotalLines += n
+	}
+}
+
+// groupKey returns the row a counted file is attributed to
+// This is the extension, renamed by --alias, unless --by-dir, --by-owner,
+// --categorize-cmd, --sniff or --categories regroup it
+func groupKey(cfg Config, path string, info fs.FileInfo, ext string) (string, error) {
+	switch {
+	case cfg.ByDir:
+		return topDir(cfg.Dir, path), nil
+	case cfg.owners != nil:
+		if uid, ok := fileOwner(info); ok {
+			return cfg.owners.name(uid), nil
+		}
+		return unknownOwner, nil
+	case cfg.categorizer != nil:
+		return cfg.categorizer.key(path, ext), nil
+	case cfg.Sniff:
+		if !info.Mode().IsRegular() {
+			return specialType(info.Mode()), nil
+		}
+		return sniffType(path)
+	case cfg.Categories:
+		if cat := extCategory(ext); cat != "other" {
+			return cat, nil
+		}
+		return cfg.OtherLabel, nil
+	}
+	if to, ok := cfg.Aliases[ext]; ok {
+		return to, nil
+	}
+	return ext, nil
+}
+
+// topDir returns the first directory of path below root
+// Files directly in root (or outside it) are grouped under "."
+func topDir(root, path string) string {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return "."
+	}
+	first, _, found := strings.Cut(rel, string(filepath.Separator))
+	if !found {
+		return "."
+	}
+	return first
+}
+
+// binarySniffLen is how many leading bytes are checked for NUL by countLines
+const binarySniffLen = 8000
+
+// countLines returns the number of newline bytes in a text file
+// Files with a NUL byte in their first binarySniffLen bytes are treated as binary and count 0
+func countLines(path string) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var lines int64
+	var read int64
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := f.Read(buf)
+		chunk := buf[:n]
+		if read < binarySniffLen {
+			head := chunk
+			if int64(len(head)) > binarySniffLen-read {
+				head = head[:binarySniffLen-read]
+			}
+			if bytes.IndexByte(head, 0) >= 0 {
+				return 0, nil
+			}
+		}
+		read += int64(n)
+		lines += int64(bytes.Count(chunk, []byte{'\n'}))
+		if err == io.EOF {
+			return lines, nil
+		}
+		if err != nil {
+			return lines, err
+		}
+	}
+}
+
+// defaultCompoundExts are the double extensions recognized by a bare --compound-ext
+var defaultCompoundExts = []string{"tar.gz", "tar.bz2", "tar.xz"}
+
+// compoundExt returns the suffix of name (with its leading dot) matching one of
+// cfg.CompoundExts, compared case-insensitively, or "" if none match
+func compoundExt(cfg Config, name string) string {
+	lower := strings.ToLower(name)
+	for _, c := range cfg.CompoundExts {
+		suffix := "." + strings.ToLower(c)
+		if len(lower) > len(suffix) && strings.HasSuffix(lower, suffix) {
+			return name[len(name)-len(suffix):]
+		}
+	}
+	return ""
+}
+
+// fileExt returns the grouping key for a file name
+// Files without an extension are grouped under "[noext]", or under their
+// own name with --group-noext-by-name
+func fileExt(cfg Config, name string) string {
+	ext := compoundExt(cfg, name)
+	if ext == "" {
+		ext = filepath.Ext(name)
+	}
+	if ext == "" {
+		if !cfg.NoExtByName {
+			return "[noext]"
+		}
+		ext = name
+	}
+	ext = strings.TrimPrefix(ext, ".")
+	if cfg.FoldCase {
+		ext = strings.ToLower(ext)
+	}
+	return ext
+}
+
+// merge folds the totals of o into r
+func (r *ScanResult) merge(o *ScanResult) {
+	for k, v := range o.Counts {
+		r.Counts[k] += v
+	}
+	for k, v := range o.SizeCounts {
+		r.SizeCounts[k] += v
+	}
+	for k, v := range o.Largest {
+		r.trackLargest(k, v)
+	}
+	for k, v := range o.Lines {
+		r.Lines[k] += v
+	}
+	r.TotalLines += o.TotalLines
+	for k, v := range o.Empty {
+		r.Empty[k] += v
+	}
+	r.Perms.merge(o.Perms)
+	r.Total += o.Total
+	r.TotalBytes += o.TotalBytes
+	r.Sizes = append(r.Sizes, o.Sizes...)
+	r.Files = append(r.Files, o.Files...)
+	r.Hashable = append(r.Hashable, o.Hashable...)
+	r.Errors = append(r.Errors, o.Errors...)
+	r.Truncated = r.Truncated || o.Truncated
+	r.Interrupted = r.Interrupted || o.Interrupted
+	r.Archives += o.Archives
+	r.Ages.merge(o.Ages)
+	if o.AgeTotals != nil {
+		if r.AgeTotals == nil {
+			r.AgeTotals = make([]dirTotal, len(ageBuckets))
+		}
+		for i, t := range o.AgeTotals {
+			r.AgeTotals[i].Count += t.Count
+			r.AgeTotals[i].Size += t.Size
+		}
+	}
+	for k, v := range o.KeyAges {
+		a := r.KeyAges[k]
+		a.merge(v)
+		r.KeyAges[k] = a
+	}
+	for k, v := range o.Depths {
+		r.Depths[k] += v
+	}
+	for k, v := range o.Hist {
+		if r.Hist[k] == nil {
+			r.Hist[k] = make([]int, len(v))
+		}
+		for i, n := range v {
+			r.Hist[k][i] += n
+		}
+	}
+	r.ArchiveEntries += o.ArchiveEntries
+	r.DirCount += o.DirCount
+	r.WalkedDirs = append(r.WalkedDirs, o.WalkedDirs...)
+	r.DirDepth = max(r.DirDepth, o.DirDepth)
+	r.Symlinks += o.Symlinks
+	r.BrokenSymlinks += o.BrokenSymlinks
+	for k, v := range o.DirTotals {
+		t := r.DirTotals[k]
+		t.Count += v.Count
+		t.Size += v.Size
+		r.DirTotals[k] = t
+	}
+	for k, v := range o.KeySizes {
+		r.KeySizes[k] = append(r.KeySizes[k], v...)
+	}
+	for k, v := range o.BySize {
+		r.BySize[k] = append(r.BySize[k], v...)
+	}
+}
+
+// scanState is the mutable state shared by every walk of one logical scan,
+// such as all base roots, the --diff root or one --tui rescan
+type scanState struct {
+	// counted is the number of files that passed the filters so far,
+	// read by the --progress ticker and --max-files
+	counted atomic.Int64
+
+	// inodes tracks hard links already counted when --dedupe-inodes is set
+	inodes *inodeSet
+}
+
+// newScanState returns the state for a new scan configured by cfg
+func newScanState(cfg Config) *scanState {
+	s := &scanState{}
+	if cfg.DedupeInodes {
+		s.inodes = newInodeSet()
+	}
+	return s
+}
+
+// errMaxFiles stops the walk once a file beyond --max-files has been refused
+var errMaxFiles = errors.New("--max-files limit reached")
+
+// errInterrupted stops the walk once its context is cancelled by Ctrl-C
+var errInterrupted = errors.New("interrupted")
+
+// limitReached reports whether add has refused a file for going over --max-files
+// Stopping at exactly the limit would flag a tree with that many files as truncated,
+// so the walk goes on until add sees one more match and sets Truncated itself
+func limitReached(cfg Config) bool {
+	return cfg.MaxFiles > 0 && cfg.scan.counted.Load() > cfg.MaxFiles
+}
+
+// startProgress prints the running file count of scan to w twice a second
+// The returned stop function halts the ticker and clears the line
+func startProgress(w io.Writer, scan *scanState) func() {
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(500 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				fmt.Fprintf(w, "\rScanning... %d files", scan.counted.Load())
+			case <-done:
+				fmt.Fprint(w, "\r\x1b[K")
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-finished
+	}
+}
+
+// ScanError is an entry that could not be scanned, reported in --json output
+type ScanError struct {
+	Path  string `json:"path" yaml:"path"`
+	Error string `json:"error" yaml:"error"`
+}
+
+// skip warns that path was skipped because of err and records it in r
+// r may be nil under --dry-run, where nothing is recorded
+func (r *ScanResult) skip(cfg Config, path string, err error) {
+	warnSkip(cfg, path, err)
+	if r != nil {
+		r.Errors = append(r.Errors, ScanError{Path: path, Error: err.Error()})
+	}
+}
+
+// warnSkip reports on stderr that what was skipped because of err, unless --quiet is set
+func warnSkip(cfg Config, what string, err error) {
+	if cfg.Quiet {
+		return
+	}
+	fmt.Fprintln(os.Stderr, "Skipping", what, "due to error:", err)
+}
+
+// tracef prints a --dry-run trace line to stderr
+func tracef(cfg Config, format string, args ...any) {
+	if cfg.DryRun {
+		fmt.Fprintf(os.Stderr, format+"\n", args...)
+	}
+}
+
+// walkJob is a file discovered by the walker and waiting to be stat'ed
+type walkJob struct {
+	path string
+	d    fs.DirEntry
+}
+
+// walkDir scans the directory recursively and counts files by extension
+// Directory traversal is sequential; files are stat'ed by cfg.Workers goroutines
+// Applies filters for hidden files, min/max size, and excluded extensions/dirs
+// Cancelling ctx ends the walk early with Interrupted set instead of an error
+func walkDir(ctx context.Context, cfg Config) (*ScanResult, error) {
+	workers := cfg.Workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	jobs := make(chan walkJob, workers*64)
+	results := make([]*ScanResult, workers)
+	// process stats a single job and records it into local
+	// local may be nil under --dry-run since add only traces in that mode
+	process := func(job walkJob, local *ScanResult) {
+		info, err := job.d.Info()
+		if err != nil {
+			local.skip(cfg, job.path, err)
+			return
+		}
+		local.add(cfg, job.path, info)
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		local := newScanResult()
+		results[w] = local
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				process(job, local)
+			}
+		}()
+	}
+
+	// visited holds the real paths of walked directories when following symlinks
+	visited := make(map[string]struct{})
+
+	var ignore *gitIgnore
+	if cfg.GitIgnore {
+		ignore = newGitIgnore(cfg.Dir)
+	}
+
+	// walked holds what the walking goroutine itself records: directory
+	// counts and walk errors
+	walked := &ScanResult{}
+
+	var visit fs.WalkDirFunc
+	visit = func(path string, d fs.DirEntry, err error) error {
+		if ctx.Err() != nil {
+			return errInterrupted
+		}
+		if limitReached(cfg) {
+			return errMaxFiles
+		}
+		if err != nil {
+			if path == cfg.Dir && d == nil {
+				// The target itself could not be read, which is fatal
+				return err
+			}
+			walked.skip(cfg, path, err)
+			return nil
+		}
+		if d.IsDir() {
+			if reason := dirReason(cfg, path, d.Name()); reason != "" {
+				tracef(cfg, "skip     %s (%s)", path, reason)
+				return filepath.SkipDir
+			}
+			if cfg.FollowSymlinks {
+				real, err := filepath.EvalSymlinks(path)
+				if err != nil {
+					walked.skip(cfg, path, err)
+					return filepath.SkipDir
+				}
+				if _, seen := visited[real]; seen {
+					tracef(cfg, "skip     %s (already visited)", path)
+					return filepath.SkipDir
+				}
+				visited[real] = struct{}{}
+			}
+			if ignore != nil {
+				if path != cfg.Dir && ignore.ignored(path, true) {
+					tracef(cfg, "skip     %s (matches .gitignore)", path)
+					return filepath.SkipDir
+				}
+				if err := ignore.load(path); err != nil {
+					warnSkip(cfg, ".gitignore in "+path, err)
+				}
+			}
+			tracef(cfg, "descend  %s", path)
+			if cfg.FindEmptyDirs {
+				walked.WalkedDirs = append(walked.WalkedDirs, filepath.Clean(path))
+			}
+			if cfg.CountDirs && path != cfg.Dir {
+				walked.DirCount++
+				walked.DirDepth = max(walked.DirDepth, relDepth(cfg.Dir, path)+1)
+			}
+			return nil
+		}
+		if cfg.FollowSymlinks && d.Type()&fs.ModeSymlink != 0 {
+			if target, err := os.Stat(path); err == nil && target.IsDir() {
+				// A trailing separator makes WalkDir resolve the link as its root
+				return filepath.WalkDir(path+string(filepath.Separator), visit)
+			}
+		}
+		if reason := hiddenReason(cfg, d.Name()); reason != "" {
+			tracef(cfg, "skip     %s (%s)", path, reason)
+			return nil
+		}
+		if ignore != nil && ignore.ignored(path, false) {
+			tracef(cfg, "skip     %s (matches .gitignore)", path)
+			return nil
+		}
+		if cfg.CountSymlinks && d.Type()&fs.ModeSymlink != 0 {
+			walked.addSymlink(cfg, path)
+			return nil
+		}
+
+		if cfg.DryRun {
+			// Trace inline so file lines interleave correctly with directory lines
+			process(walkJob{path, d}, nil)
+			return nil
+		}
+		jobs <- walkJob{path, d}
+		return nil
+	}
+
+	err := filepath.WalkDir(cfg.Dir, visit)
+	switch {
+	case errors.Is(err, errMaxFiles):
+		err = nil
+	case errors.Is(err, errInterrupted):
+		walked.Interrupted = true
+		err = nil
+	}
+
+	close(jobs)
+	wg.Wait()
+
+	res := newScanResult()
+	for _, r := range results {
+		res.merge(r)
+	}
+	res.merge(walked)
+	return res, err
+}
+
+// scanList counts the newline-separated file paths read from r
+// Each path is Lstat'ed and goes through the same filters as walkDir
+// Missing or unreadable paths are reported to stderr and skipped
+func scanList(ctx context.Context, cfg Config, r io.Reader) (*ScanResult, error) {
+	res := newScanResult()
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		if ctx.Err() != nil {
+			res.Interrupted = true
+			break
+		}
+		path := strings.TrimRight(sc.Text(), "\r")
+		if path == "" {
+			continue
+		}
+
+		info, err := os.Lstat(path)
+		if err != nil {
+			res.skip(cfg, path, err)
+			continue
+		}
+		if info.IsDir() {
+			tracef(cfg, "skip     %s (directory)", path)
+			continue
+		}
+		if inExcludedDir(cfg, path) {
+			tracef(cfg, "skip     %s (excluded directory)", path)
+			continue
+		}
+		if reason := hiddenReason(cfg, info.Name()); reason != "" {
+			tracef(cfg, "skip     %s (%s)", path, reason)
+			continue
+		}
+		if cfg.CountSymlinks && info.Mode()&fs.ModeSymlink != 0 {
+			res.addSymlink(cfg, path)
+			continue
+		}
+
+		if limitReached(cfg) {
+			break
+		}
+		res.add(cfg, path, info)
+	}
+	return res, sc.Err()
+}
+
+// dirReason returns why the directory at path (named name) is pruned from a
+// walk rooted at cfg.Dir, or "" if it should be descended into
+func dirReason(cfg Config, path, name string) string {
+	if _, skip := cfg.ExcludeDirs[name]; skip {
+		return "excluded directory"
+	}
+	if path == cfg.Dir {
+		return ""
+	}
+	switch {
+	case excludedDirPath(cfg, path):
+		return "excluded directory path"
+	case !cfg.IncludeHidden && !cfg.HiddenDirs && strings.HasPrefix(name, "."):
+		return "hidden directory"
+	// Files inside this directory sit one level below it
+	// --tree uses --maxdepth for rendering only, so everything is still summed
+	case cfg.MaxDepth >= 0 && !cfg.Tree && relDepth(cfg.Dir, path) >= cfg.MaxDepth:
+		return "beyond --maxdepth"
+	}
+	return ""
+}
+
+// hiddenReason returns why a file named name is skipped for being (or, with
+// --hidden-only, not being) a dotfile, or "" if it should be kept
+func hiddenReason(cfg Config, name string) string {
+	hidden := strings.HasPrefix(name, ".")
+	switch {
+	case cfg.HiddenOnly && !hidden:
+		return "not hidden"
+	case !cfg.IncludeHidden && hidden:
+		return "hidden"
+	}
+	return ""
+}
+
+// inExcludedDir reports whether any directory component of path is in --excludedir
+func inExcludedDir(cfg Config, path string) bool {
+	for _, part := range strings.Split(filepath.Dir(filepath.Clean(path)), string(filepath.Separator)) {
+		if _, skip := cfg.ExcludeDirs[part]; skip {
+			return true
+		}
+	}
+	return false
+}
+
+// grandTotals walks every root once without the per-file filters (sizes, ages,
+// extensions, globs and patterns) to get the totals --relative-to-all compares against
+// Directory rules such as --excludedir, --maxdepth and hidden handling still apply
+func grandTotals(ctx context.Context, cfg Config) (int, int64, error) {
+	c := cfg
+	c.MinSize, c.MaxSize = 0, 0
+	c.NewerThan, c.OlderThan = time.Time{}, time.Time{}
+	c.Exclude, c.Include = nil, nil
+	c.ExcludeGlobs, c.ExcludeRegex = nil, nil
+	// Only the totals are needed, so skip the expensive per-file work
+	c.Lines, c.Sniff, c.Dupes, c.Percentiles, c.Median, c.MedianPerExt = false, false, false, false, false, false
+	c.List, c.Tree, c.FindEmptyDirs, c.Histogram, c.Age, c.AgePerExt = false, false, false, false, false, false
+	c.Manifest, c.AgeBuckets, c.DryRun = false, false, false
+	c.categorizer, c.cache = nil, nil
+	// Its own scan state keeps the pre-pass out of --progress and --max-files
+	// and lets the real scan still see every hard link as new
+	c.scan = newScanState(c)
+
+	r, err := scanTargets(ctx, c)
+	if err != nil {
+		return 0, 0, err
+	}
+	return r.Total, r.TotalBytes, nil
+}
 
-// aggregateStats groups small categories into "other" unless --verbose is set
-// Sorts results by count or by size depending on cfg.BySize
-func aggregateStats(cfg Config, counts map[string]int, sizeCounts map[string]int64, total int, totalBytes int64) []FileStat {
-	stats := []FileStat{}
+// scanTargets scans every positional argument in cfg.Dirs and merges the results
+// Glob arguments are expanded up front and scanned like --stdin paths;
+// directories are walked, with overlapping trees only walked once
+// A glob match is dropped when a walked directory or an earlier glob already covers it
+func scanTargets(ctx context.Context, cfg Config) (*ScanResult, error) {
+	res := newScanResult()
+	var dirs, globs []string
+	for _, dir := range cfg.Dirs {
+		if isGlob(dir) {
+			globs = append(globs, dir)
+		} else {
+			dirs = append(dirs, dir)
+		}
+	}
+	roots := uniqueRoots(dirs)
+	absRoots := make([]string, len(roots))
+	for i, root := range roots {
+		absRoots[i] = absPath(root)
+	}
 
-	if cfg.BySize {
-		var other int64
-		for k, v := range sizeCounts {
-			percent := safeDivF(float64(v), float64(totalBytes))
-			if !cfg.Verbose && percent < 0.01 {
-				other += v
-			} else {
-				stats = append(stats, FileStat{k, 0, v})
+	seen := make(map[string]struct{})
+	for _, dir := range globs {
+		c := cfg
+		base, matches := expandGlob(c, dir)
+		c.Dir = base
+		kept := matches[:0]
+		for _, m := range matches {
+			abs := absPath(m)
+			if _, dup := seen[abs]; dup || withinAny(absRoots, abs) {
+				tracef(cfg, "skip     %s (already scanned)", m)
+				continue
 			}
+			seen[abs] = struct{}{}
+			kept = append(kept, m)
+		}
+		r, err := scanList(ctx, c, strings.NewReader(strings.Join(kept, "\n")))
+		if err != nil {
+			return nil, fmt.Errorf("scanning matches of %s: %v", dir, err)
 		}
-		if other > 0 {
-			stats = append(stats, FileStat{"other", 0, other})
+		res.merge(r)
+	}
+	for _, dir := range roots {
+		c := cfg
+		c.Dir = dir
+		r, err := walkDir(ctx, c)
+		if err != nil {
+			return nil, err
 		}
-		sort.Slice(stats, func(i, j int) bool {
-			return stats[i].Size > stats[j].Size
-		})
-	} else {
-		var other int
-		for k, v := range counts {
-			percent := safeDivF(float64(v), float64(total))
-			if !cfg.Verbose && percent < 0.01 {
-				other += v
-			} else {
-				stats = append(stats, FileStat{k, v, 0})
+		res.merge(r)
+	}
+	return res, nil
+}
+
+// absPath returns p made absolute, or just cleaned if that fails
+func absPath(p string) string {
+	if abs, err := filepath.Abs(p); err == nil {
+		return abs
+	}
+	return filepath.Clean(p)
+}
+
+// withinAny reports whether the absolute path p is one of roots or lies below one
+func withinAny(roots []string, p string) bool {
+	for _, root := range roots {
+		if rel, err := filepath.Rel(root, p); err == nil && !strings.HasPrefix(rel, "..") {
+			return true
+		}
+	}
+	return false
+}
+
+// checkDir returns a friendly error when dir is missing or is not a directory
+func checkDir(dir string) error {
+	info, err := os.Stat(dir)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return fmt.Errorf("directory %q does not exist", dir)
+	case err != nil:
+		return err
+	case !info.IsDir():
+		return fmt.Errorf("%q is not a directory", dir)
+	}
+	return nil
+}
+
+// uniqueRoots drops directories that repeat or sit inside another listed directory
+// so overlapping trees are only walked once; order is otherwise preserved
+func uniqueRoots(dirs []string) []string {
+	abs := make([]string, len(dirs))
+	for i, d := range dirs {
+		a, err := filepath.Abs(d)
+		if err != nil {
+			a = filepath.Clean(d)
+		}
+		abs[i] = a
+	}
+
+	var roots []string
+	for i, d := range dirs {
+		covered := false
+		for j := range dirs {
+			if i == j {
+				continue
+			}
+			rel, err := filepath.Rel(abs[j], abs[i])
+			if err != nil || strings.HasPrefix(rel, "..") {
+				continue
+			}
+			// Identical paths keep the first occurrence, nested ones keep the ancestor
+			if rel != "." || j < i {
+				covered = true
+				break
 			}
 		}
-		if other > 0 {
-			stats = append(stats, FileStat{"other", other, 0})
+		if !covered {
+			roots = append(roots, d)
+		}
+	}
+	return roots
+}
+
+// excludedDirPath reports whether dir, relative to cfg.Dir, matches an --excludedir-path pattern
+func excludedDirPath(cfg Config, dir string) bool {
+	if len(cfg.ExcludeDirPaths) == 0 {
+		return false
+	}
+	rel, err := filepath.Rel(cfg.Dir, dir)
+	if err != nil {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+	for _, pattern := range cfg.ExcludeDirPaths {
+		if ok, _ := filepath.Match(pattern, rel); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// relDepth returns how many directories deep path is below root
+// Entries directly inside root have depth 0
+func relDepth(root, path string) int {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." {
+		return 0
+	}
+	return strings.Count(rel, string(filepath.Separator))
+}
+
+// aggregateStats groups small categories into "other" (cfg.OtherLabel) unless --verbose is set
+// Small means under cfg.Threshold percent (1 by default) or, with --min-count,
+// fewer files than that count
+// An existing key equal to the label (e.g. from --categories) is merged into that bucket
+// With --no-other small entries are dropped instead and no bucket is added
+// Sorts results by name with --sort name, otherwise by count, size or lines
+// Ties are broken by name and "other" is always placed last, even with --reverse
+func aggregateStats(cfg Config, res *ScanResult) []FileStat {
+	stats := []FileStat{}
+	other := FileStat{Ext: cfg.OtherLabel}
+
+	for k, n := range res.Counts {
+		s := FileStat{Ext: k, Count: n, Size: res.SizeCounts[k], Lines: res.Lines[k]}
+		if l, ok := res.Largest[k]; ok {
+			s.Largest, s.LargestPath = l.Size, l.Path
+		}
+
+		var percent float64
+		switch {
+		case cfg.ByLines:
+			percent = safeDivF(float64(s.Lines), float64(res.TotalLines))
+		case cfg.BySize:
+			percent = safeDivF(float64(s.Size), float64(res.TotalBytes))
+		default:
+			percent = safeDivF(float64(s.Count), float64(res.Total))
+		}
+
+		small := percent < cfg.Threshold/100 || s.Count < cfg.MinCount
+		if cfg.NoOther && !cfg.Verbose && small {
+			// Dropped rather than folded, so percentages no longer sum to 100
+			continue
+		}
+		if (k == cfg.OtherLabel && !cfg.NoOther) || (!cfg.Verbose && small) {
+			other.absorb(s)
+		} else {
+			stats = append(stats, s)
+		}
+	}
+	if other.Count > 0 {
+		stats = append(stats, other)
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		a, b := stats[i], stats[j]
+		// Keep "other" last so it never interleaves with real entries
+		if (a.Ext == cfg.OtherLabel) != (b.Ext == cfg.OtherLabel) {
+			return b.Ext == cfg.OtherLabel
+		}
+		if cfg.Reverse {
+			a, b = b, a
+		}
+		switch {
+		case cfg.Sort == "name":
+		case cfg.ByLines:
+			if a.Lines != b.Lines {
+				return a.Lines > b.Lines
+			}
+		case cfg.BySize && a.Size != b.Size:
+			return a.Size > b.Size
+		case !cfg.BySize && a.Count != b.Count:
+			return a.Count > b.Count
+		}
+		// Break ties by name so output is reproducible across runs
+		return a.Ext < b.Ext
+	})
+
+	if cfg.Top > 0 {
+		if cfg.NoOther {
+			stats = stats[:min(cfg.Top, len(stats))]
+		} else {
+			stats = applyTop(stats, cfg.Top, cfg.OtherLabel)
 		}
-		sort.Slice(stats, func(i, j int) bool {
-			return stats[i].Count > stats[j].Count
-		})
 	}
 
 	return stats
 }
 
+// absorb folds o into s, keeping the larger of the two largest files
+func (s *FileStat) absorb(o FileStat) {
+	s.Count += o.Count
+	s.Size += o.Size
+	s.Lines += o.Lines
+	if o.LargestPath == "" {
+		return
+	}
+	cur := fileRef{s.LargestPath, s.Largest}
+	if s.LargestPath == "" || (fileRef{o.LargestPath, o.Largest}).beats(cur) {
+		s.Largest, s.LargestPath = o.Largest, o.LargestPath
+	}
+}
+
+// applyTop keeps the first n sorted entries and folds the remainder into the bucket named label
+// The "other" bucket is always placed last so it never counts towards n
+func applyTop(stats []FileStat, n int, label string) []FileStat {
+	kept := []FileStat{}
+	other := FileStat{Ext: label}
+	for _, s := range stats {
+		if s.Ext != label && len(kept) < n {
+			kept = append(kept, s)
+			continue
+		}
+		other.absorb(s)
+	}
+	if other.Count > 0 {
+		kept = append(kept, other)
+	}
+	return kept
+}
+
 // printStats displays the results with ASCII bar chart unless --nobar is set
-func printStats(cfg Config, stats []FileStat, total int, totalBytes int64) {
-	barWidth := 40
+// --summary-only keeps just the footer
+func printStats(w io.Writer, cfg Config, stats []FileStat, total int, totalBytes int64) {
+	if cfg.SummaryOnly {
+		printFooter(w, cfg, total, totalBytes)
+		return
+	}
+	barWidth := cfg.BarWidth
+	color := useColor(cfg, w)
 	if !cfg.NoBar {
-		fmt.Println("File type breakdown:")
+		switch {
+		case cfg.ByDir:
+			fmt.Fprintln(w, "Directory breakdown:")
+		case cfg.ByOwner:
+			fmt.Fprintln(w, "Owner breakdown:")
+		default:
+			fmt.Fprintln(w, "File type breakdown:")
+		}
 	}
 
 	for _, s := range stats {
-		var percent float64
-		if cfg.BySize {
-			percent = safeDivF(float64(s.Size), float64(totalBytes)) * 100
+		percent := statPercent(cfg, s, total, totalBytes)
+
+		var line string
+		if cfg.Absolute {
+			line = fmt.Sprintf("%-10s %8s %10s", s.Ext, formatCount(cfg, int64(s.Count)), formatSize(cfg, s.Size))
+		} else if cfg.NoBar {
+			line = fmt.Sprintf("%-10s %s%%", s.Ext, localef(cfg, "%5.0f", percent))
 		} else {
-			percent = safeDivF(float64(s.Count), float64(total)) * 100
+			// --human rounding can push percent past 100, so clamp to the bar
+			barLen := min(max(int(percent/100*float64(barWidth)), 0), barWidth)
+			if percent > 0 {
+				// Keep small but present rows visible; the dashes shrink to fit
+				barLen = max(barLen, min(cfg.MinBar, barWidth))
+			}
+			filled := barFill(cfg.BarChar, barLen)
+			if color {
+				filled = extColor(cfg, s.Ext) + filled + ansiReset
+			}
+			bar := filled + strings.Repeat("-", barWidth-barLen)
+			line = fmt.Sprintf("%-10s |%s| %s%%", s.Ext, bar, localef(cfg, "%5.2f", percent))
 		}
 
-		if cfg.Human {
-			percent = float64(int(percent + 0.5))
+		if cfg.Lines {
+			line += fmt.Sprintf("  lines %10s", formatCount(cfg, s.Lines))
 		}
+		if cfg.Avg {
+			avg := int64(safeDivF(float64(s.Size), float64(s.Count)))
+			line += fmt.Sprintf("  avg %10s", formatSize(cfg, avg))
+		}
+		if cfg.ShowLargest && s.LargestPath != "" {
+			line += fmt.Sprintf("  largest: %s (%s)", displayPath(cfg, s.LargestPath), formatSize(cfg, s.Largest))
+		}
+		fmt.Fprintln(w, line)
+	}
 
-		if cfg.NoBar {
-			fmt.Printf("%-10s %5.0f%%\n", s.Ext, percent)
-		} else {
-			barLen := int(percent / 100 * float64(barWidth))
-			bar := strings.Repeat("█", barLen) + strings.Repeat("-", barWidth-barLen)
-			fmt.Printf("%-10s |%s| %5.2f%%\n", s.Ext, bar, percent)
+	printFooter(w, cfg, total, totalBytes)
+}
+
+// barFill returns n runes taken by repeating char, so a multi-rune
+// --bar-char keeps the bar exactly n columns wide
+func barFill(char string, n int) string {
+	runes := []rune(char)
+	out := make([]rune, n)
+	for i := range out {
+		out[i] = runes[i%len(runes)]
+	}
+	return string(out)
+}
+
+// printTiming reports how long the scan took and its throughput
+// It goes to stderr so structured output on stdout stays clean
+func printTiming(w io.Writer, res *ScanResult, elapsed time.Duration) {
+	secs := elapsed.Seconds()
+	shown := elapsed.Round(time.Millisecond)
+	if shown == 0 {
+		shown = elapsed.Round(time.Microsecond)
+	}
+	fmt.Fprintf(w, "Scanned %d files in %s: %.0f files/s, %.2f MB/s\n",
+		res.Total, shown,
+		safeDivF(float64(res.Total), secs),
+		safeDivF(float64(res.TotalBytes)/(1024*1024), secs))
+}
+
+// printFooter prints the one-line rollup of file count, total size and average size
+func printFooter(w io.Writer, cfg Config, total int, totalBytes int64) {
+	avg := int64(safeDivF(float64(totalBytes), float64(total)))
+	fmt.Fprintf(w, "Total: %s files, %s, avg %s", formatCount(cfg, int64(total)), formatSize(cfg, totalBytes), formatSize(cfg, avg))
+	if cfg.RelativeToAll {
+		fmt.Fprintf(w, " (of %s files, %s overall)", formatCount(cfg, int64(cfg.allTotal)), formatSize(cfg, cfg.allBytes))
+	}
+	fmt.Fprintln(w)
+}
+
+// formatCount renders a count for human-oriented output
+// --thousands groups digits with commas, e.g. 1,234,567, or with the
+// --locale separator when one is set
+func formatCount(cfg Config, n int64) string {
+	digits := strconv.FormatInt(n, 10)
+	if !cfg.Thousands {
+		return digits
+	}
+	if cfg.printer != nil {
+		return cfg.printer.Sprint(n)
+	}
+	sign := ""
+	if n < 0 {
+		sign, digits = "-", digits[1:]
+	}
+	var b strings.Builder
+	for i, c := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(c)
+	}
+	return sign + b.String()
+}
+
+// onelineDefault is how many rows --oneline shows when --top is not given
+const onelineDefault = 3
+
+// printOneline writes a compact summary such as "go:42 js:30 (+5 other) 2.10 MB"
+// groups is the number of distinct keys scanned, used to count what was left out
+func printOneline(w io.Writer, cfg Config, stats []FileStat, groups int, totalBytes int64) {
+	n := onelineDefault
+	if cfg.Top > 0 {
+		n = cfg.Top
+	}
+	parts := []string{}
+	for _, s := range stats {
+		if s.Ext == cfg.OtherLabel || len(parts) == n {
+			break
+		}
+		parts = append(parts, fmt.Sprintf("%s:%d", s.Ext, s.Count))
+	}
+	if hidden := groups - len(parts); hidden > 0 {
+		parts = append(parts, fmt.Sprintf("(+%d %s)", hidden, cfg.OtherLabel))
+	}
+	parts = append(parts, formatSize(cfg, totalBytes))
+	fmt.Fprintln(w, strings.Join(parts, " "))
+}
+
+// ansiReset ends an ANSI color sequence
+const ansiReset = "\x1b[0m"
+
+// barPalette holds the ANSI colors cycled through by extColor
+var barPalette = []string{
+	"\x1b[31m", "\x1b[32m", "\x1b[33m", "\x1b[34m", "\x1b[35m", "\x1b[36m",
+	"\x1b[91m", "\x1b[92m", "\x1b[93m", "\x1b[94m", "\x1b[95m", "\x1b[96m",
+}
+
+// colorNames maps the names accepted by --color-rule to ANSI colors
+var colorNames = map[string]string{
+	"black": "\x1b[30m", "red": "\x1b[31m", "green": "\x1b[32m", "yellow": "\x1b[33m",
+	"blue": "\x1b[34m", "magenta": "\x1b[35m", "cyan": "\x1b[36m", "white": "\x1b[37m",
+	"gray": "\x1b[90m", "bright-red": "\x1b[91m", "bright-green": "\x1b[92m", "bright-yellow": "\x1b[93m",
+	"bright-blue": "\x1b[94m", "bright-magenta": "\x1b[95m", "bright-cyan": "\x1b[96m", "bright-white": "\x1b[97m",
+}
+
+// colorNameList returns the --color-rule color names, sorted for error messages
+func colorNameList() []string {
+	names := make([]string, 0, len(colorNames))
+	for name := range colorNames {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// extColor returns the --color-rule color for ext, or else picks a palette
+// entry by hashing ext, so an extension keeps its color across runs
+func extColor(cfg Config, ext string) string {
+	if code, ok := cfg.ColorRules[ext]; ok {
+		return code
+	}
+	h := fnv.New32a()
+	h.Write([]byte(ext))
+	return barPalette[h.Sum32()%uint32(len(barPalette))]
+}
+
+// useColor reports whether bars written to w should be colorized
+// "auto" only colors when w is a terminal, "always" colors regardless
+// --no-color and a non-empty NO_COLOR environment variable override both
+func useColor(cfg Config, w io.Writer) bool {
+	if cfg.NoColor || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	switch cfg.Color {
+	case "always":
+		return true
+	case "auto":
+		return isTerminal(w)
+	default:
+		return false
+	}
+}
+
+// isTerminal reports whether w is a character device such as a TTY
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// displayPath rewrites path relative to --relative-to (or the scanned directory)
+// Paths outside the base are made absolute; without the flag path is returned as is
+func displayPath(cfg Config, path string) string {
+	if !cfg.Relative {
+		return path
+	}
+	base := cfg.RelativeTo
+	if base == "" {
+		base = cfg.Dir
+	}
+	absBase, err := filepath.Abs(base)
+	if err != nil {
+		return path
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	rel, err := filepath.Rel(absBase, absPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return absPath
+	}
+	return rel
+}
+
+// printExtOnly prints the distinct keys that had matching files, one per line
+func printExtOnly(w io.Writer, counts map[string]int) {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintln(w, k)
+	}
+}
+
+// printList prints every counted file with its size, largest first
+// --sort name orders by path instead
+func printList(w io.Writer, cfg Config, files []fileRef) {
+	sort.Slice(files, func(i, j int) bool {
+		if cfg.Sort == "name" {
+			return files[i].Path < files[j].Path
+		}
+		return files[i].beats(files[j])
+	})
+	for _, f := range files {
+		fmt.Fprintf(w, "%10s  %s\n", formatSize(cfg, f.Size), displayPath(cfg, f.Path))
+	}
+}
+
+// printEmpty lists how many zero-byte files each row contains
+func printEmpty(w io.Writer, empty map[string]int) {
+	keys := make([]string, 0, len(empty))
+	total := 0
+	for k, n := range empty {
+		keys = append(keys, k)
+		total += n
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if empty[keys[i]] != empty[keys[j]] {
+			return empty[keys[i]] > empty[keys[j]]
 		}
+		return keys[i] < keys[j]
+	})
+
+	fmt.Fprintf(w, "Empty files: %d\n", total)
+	for _, k := range keys {
+		fmt.Fprintf(w, "    %-10s %d\n", k, empty[k])
+	}
+}
+
+// printDepths prints how many files sit at each directory depth, shallowest first
+func printDepths(w io.Writer, depths map[int]int) {
+	levels := make([]int, 0, len(depths))
+	for d := range depths {
+		levels = append(levels, d)
+	}
+	sort.Ints(levels)
+	fmt.Fprintln(w, "Files by depth:")
+	for _, d := range levels {
+		fmt.Fprintf(w, "    %d: %d\n", d, depths[d])
+	}
+}
+
+// printPercentiles prints the p50/p90/p99 file sizes of the whole scan
+func printPercentiles(w io.Writer, cfg Config, sizes []int64) {
+	sort.Slice(sizes, func(i, j int) bool { return sizes[i] < sizes[j] })
+	fmt.Fprintf(w, "Size percentiles: p50 %s, p90 %s, p99 %s\n",
+		formatSize(cfg, percentile(sizes, 50)),
+		formatSize(cfg, percentile(sizes, 90)),
+		formatSize(cfg, percentile(sizes, 99)))
+}
+
+// printMedian prints the median file size of the whole scan
+// and, when --median-per-ext collected them, of every row in name order
+func printMedian(w io.Writer, cfg Config, sizes []int64, keySizes map[string][]int64) {
+	fmt.Fprintf(w, "Median size: %s\n", formatSize(cfg, median(sizes)))
+	keys := make([]string, 0, len(keySizes))
+	for k := range keySizes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(w, "    %-10s %s\n", k, formatSize(cfg, median(keySizes[k])))
+	}
+}
+
+// median sorts sizes in place and returns their median
+// An even count averages the two middle values
+func median(sizes []int64) int64 {
+	if len(sizes) == 0 {
+		return 0
 	}
+	sort.Slice(sizes, func(i, j int) bool { return sizes[i] < sizes[j] })
+	mid := len(sizes) / 2
+	if len(sizes)%2 == 1 {
+		return sizes[mid]
+	}
+	return (sizes[mid-1] + sizes[mid]) / 2
 }
 
-// humanReadableSize formats a byte count into KB/MB/GB/TB string
-func humanReadableSize(bytes int64) string {
-	const (
-		KB = 1024
-		MB = 1024 * KB
-		GB = 1024 * MB
-		TB = 1024 * GB
-	)
+// percentile returns the nearest-rank p-th percentile of an ascending slice
+func percentile(sorted []int64, p float64) int64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	return sorted[idx]
+}
 
+// statPercent returns the share of a FileStat as a percentage
+// Uses lines, size or count depending on --sort, rounded when --human is set
+func statPercent(cfg Config, s FileStat, total int, totalBytes int64) float64 {
+	if cfg.RelativeToAll {
+		total, totalBytes = cfg.allTotal, cfg.allBytes
+	}
+	var percent float64
 	switch {
-	case bytes >= TB:
-		return fmt.Sprintf("%.2f TB", float64(bytes)/TB)
-	case bytes >= GB:
-		return fmt.Sprintf("%.2f GB", float64(bytes)/GB)
-	case bytes >= MB:
-		return fmt.Sprintf("%.2f MB", float64(bytes)/MB)
-	case bytes >= KB:
-		return fmt.Sprintf("%.2f KB", float64(bytes)/KB)
+	case cfg.ByLines:
+		percent = safeDivF(float64(s.Lines), float64(cfg.totalLines)) * 100
+	case cfg.BySize:
+		percent = safeDivF(float64(s.Size), float64(totalBytes)) * 100
 	default:
+		percent = safeDivF(float64(s.Count), float64(total)) * 100
+	}
+
+	if cfg.Human {
+		percent = float64(int(percent + 0.5))
+	}
+	return percent
+}
+
+// JSONStat is the JSON representation of a single FileStat row
+// The yaml tags keep --yaml output identical in shape
+type JSONStat struct {
+	Ext     string  `json:"ext" yaml:"ext"`
+	Count   int     `json:"count" yaml:"count"`
+	Size    int64   `json:"size" yaml:"size"`
+	Percent float64 `json:"percent" yaml:"percent"`
+}
+
+// newJSONStat converts a FileStat into its JSON row
+func newJSONStat(cfg Config, s FileStat, total int, totalBytes int64) JSONStat {
+	return JSONStat{
+		Ext:     s.Ext,
+		Count:   s.Count,
+		Size:    s.Size,
+		Percent: roundPercent(statPercent(cfg, s, total, totalBytes)),
+	}
+}
+
+// JSONReport is the top-level document written by --json and --yaml
+// TotalBytes is only present when --size is set
+type JSONReport struct {
+	Total      int         `json:"total" yaml:"total"`
+	TotalBytes *int64      `json:"totalBytes,omitempty" yaml:"totalBytes,omitempty"`
+	Stats      []JSONStat  `json:"stats" yaml:"stats"`
+	Errors     []ScanError `json:"errors,omitempty" yaml:"errors,omitempty"`
+}
+
+// newJSONReport builds the document shared by --json and --yaml
+// Entries that could not be read are listed under "errors", sorted by path
+func newJSONReport(cfg Config, stats []FileStat, total int, totalBytes int64, errs []ScanError) JSONReport {
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Path < errs[j].Path })
+	report := JSONReport{Total: total, Stats: []JSONStat{}, Errors: errs}
+	if cfg.ShowSize {
+		report.TotalBytes = &totalBytes
+	}
+
+	for _, s := range stats {
+		report.Stats = append(report.Stats, newJSONStat(cfg, s, total, totalBytes))
+	}
+	return report
+}
+
+// printJSON writes the results to w as a single JSON document
+func printJSON(w io.Writer, cfg Config, stats []FileStat, total int, totalBytes int64, errs []ScanError) error {
+	report := newJSONReport(cfg, stats, total, totalBytes, errs)
+	if cfg.JSONPretty {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", data)
+		return err
+	}
+	return json.NewEncoder(w).Encode(report)
+}
+
+// NDJSONSummary is the final line written by --ndjson so consumers can detect the end
+type NDJSONSummary struct {
+	Summary    bool  `json:"summary"`
+	Total      int   `json:"total"`
+	TotalBytes int64 `json:"totalBytes"`
+}
+
+// printNDJSON writes one JSON object per FileStat followed by a summary line
+func printNDJSON(w io.Writer, cfg Config, stats []FileStat, total int, totalBytes int64) error {
+	enc := json.NewEncoder(w)
+	for _, s := range stats {
+		if err := enc.Encode(newJSONStat(cfg, s, total, totalBytes)); err != nil {
+			return err
+		}
+	}
+	return enc.Encode(NDJSONSummary{Summary: true, Total: total, TotalBytes: totalBytes})
+}
+
+// tableRows builds the header and one row per FileStat shared by --csv and --tsv
+// Sizes are raw bytes so spreadsheets can do arithmetic on them
+func tableRows(cfg Config, stats []FileStat, total int, totalBytes int64) [][]string {
+	rows := [][]string{{"ext", "count", "size", "percent"}}
+	for _, s := range stats {
+		percent := statPercent(cfg, s, total, totalBytes)
+		rows = append(rows, []string{
+			s.Ext,
+			strconv.Itoa(s.Count),
+			strconv.FormatInt(s.Size, 10),
+			strconv.FormatFloat(roundPercent(percent), 'f', -1, 64),
+		})
+	}
+	return rows
+}
+
+// printCSV writes a header row followed by one row per FileStat
+func printCSV(w io.Writer, cfg Config, stats []FileStat, total int, totalBytes int64) error {
+	cw := csv.NewWriter(w)
+	if err := cw.WriteAll(tableRows(cfg, stats, total, totalBytes)); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+// printTSV writes the same rows as printCSV separated by tabs
+// Fields never contain tabs, so no quoting is needed
+func printTSV(w io.Writer, cfg Config, stats []FileStat, total int, totalBytes int64) error {
+	for _, row := range tableRows(cfg, stats, total, totalBytes) {
+		if _, err := fmt.Fprintln(w, strings.Join(row, "\t")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// printMarkdown writes a GitHub-flavored Markdown table with one row per FileStat
+func printMarkdown(w io.Writer, cfg Config, stats []FileStat, total int, totalBytes int64) {
+	fmt.Fprintln(w, "| Ext | Count | Size | Percent |")
+	fmt.Fprintln(w, "|-----|------:|-----:|--------:|")
+
+	for _, s := range stats {
+		percent := statPercent(cfg, s, total, totalBytes)
+		pct := localef(cfg, "%.2f%%", percent)
+		if cfg.Human {
+			pct = localef(cfg, "%.0f%%", percent)
+		}
+		// Pipes would otherwise split the cell
+		ext := strings.ReplaceAll(s.Ext, "|", "\\|")
+		fmt.Fprintf(w, "| %s | %d | %s | %s |\n", ext, s.Count, formatSize(cfg, s.Size), pct)
+	}
+}
+
+// roundPercent trims a percentage to two decimal places for machine output
+func roundPercent(p float64) float64 {
+	return math.Round(p*100) / 100
+}
+
+// formatSize renders a byte count for human-oriented output
+// --bytes switches every size to an exact integer instead of KB/MB/GB
+// and --si to powers of 1000; --locale picks the decimal separator
+func formatSize(cfg Config, bytes int64) string {
+	if cfg.Bytes {
+		return strconv.FormatInt(bytes, 10)
+	}
+	if cfg.SI {
+		return humanReadableSize(cfg, bytes, siUnits)
+	}
+	return humanReadableSize(cfg, bytes, binaryUnits)
+}
+
+// unitSystem is a divisor and the labels of its successive powers
+type unitSystem struct {
+	base   int64
+	labels []string
+}
+
+var (
+	// binaryUnits is the default, with 1 KB = 1024 bytes
+	binaryUnits = unitSystem{1024, []string{"KB", "MB", "GB", "TB"}}
+	// siUnits follows SI prefixes, with 1 kB = 1000 bytes as disk vendors count
+	siUnits = unitSystem{1000, []string{"kB", "MB", "GB", "TB"}}
+)
+
+// humanReadableSize formats a byte count into KB/MB/GB/TB string using units
+func humanReadableSize(cfg Config, bytes int64, units unitSystem) string {
+	if bytes < units.base {
 		return fmt.Sprintf("%d B", bytes)
 	}
+	div := float64(units.base)
+	i := 0
+	for i < len(units.labels)-1 && float64(bytes) >= div*float64(units.base) {
+		div *= float64(units.base)
+		i++
+	}
+	return localef(cfg, "%.2f %s", float64(bytes)/div, units.labels[i])
+}
+
+// sizeUnits maps the suffixes accepted by parseSize to byte multipliers
+var sizeUnits = map[string]int64{
+	"":   1,
+	"B":  1,
+	"K":  1 << 10,
+	"KB": 1 << 10,
+	"M":  1 << 20,
+	"MB": 1 << 20,
+	"G":  1 << 30,
+	"GB": 1 << 30,
+	"T":  1 << 40,
+	"TB": 1 << 40,
+}
+
+// parseSize is the inverse of humanReadableSize, turning "1MB" or "2.5G" into bytes
+// Units are 1024-based and case-insensitive; a bare integer is taken as raw bytes
+func parseSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if i < 0 {
+		i = len(s)
+	}
+	num, unit := s[:i], strings.ToUpper(strings.TrimSpace(s[i:]))
+
+	mult, ok := sizeUnits[unit]
+	if !ok {
+		return 0, fmt.Errorf("unknown size unit %q in %q (use B, KB, MB, GB or TB)", s[i:], s)
+	}
+	if num == "" {
+		return 0, fmt.Errorf("missing number in size %q", s)
+	}
+	if unit == "" || unit == "B" {
+		return strconv.ParseInt(num, 10, 64)
+	}
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number in size %q", s)
+	}
+	return int64(n * float64(mult)), nil
 }
 
 // safeDivF does floating point division with zero check
@@ -351,4 +3096,3 @@ func safeDivF(a, b float64) float64 {
 	}
 	return a / b
 }
-
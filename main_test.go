@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// testConfig returns the defaults parseArgs produces for args
+func testConfig(t *testing.T, args ...string) Config {
+	t.Helper()
+	cfg, err := parseArgs(append([]string{"dstat"}, args...))
+	if err != nil {
+		t.Fatalf("parseArgs(%q): %v", args, err)
+	}
+	return *cfg
+}
+
+// bars returns the bar of every breakdown line printStats wrote to out
+func bars(t *testing.T, out string) []string {
+	t.Helper()
+	var found []string
+	for _, line := range strings.Split(out, "\n") {
+		_, rest, ok := strings.Cut(line, "|")
+		if !ok {
+			continue
+		}
+		bar, _, ok := strings.Cut(rest, "|")
+		if !ok {
+			t.Fatalf("unterminated bar in %q", line)
+		}
+		found = append(found, bar)
+	}
+	return found
+}
+
+// checkBar fails unless bar is width runes of the block glyph followed only by dashes
+func checkBar(t *testing.T, bar string, width int) {
+	t.Helper()
+	runes := []rune(bar)
+	if len(runes) != width {
+		t.Errorf("bar %q is %d runes, want %d", bar, len(runes), width)
+	}
+	padding := false
+	for _, r := range runes {
+		switch {
+		case r == '-':
+			padding = true
+		case r == '\u2588' && !padding:
+		default:
+			t.Errorf("bar %q has unexpected rune %q", bar, r)
+			return
+		}
+	}
+}
+
+func TestPrintStatsBarGlyphs(t *testing.T) {
+	cfg := testConfig(t)
+	stats := []FileStat{{Ext: "go", Count: 3}, {Ext: "md", Count: 1}}
+
+	var buf bytes.Buffer
+	printStats(&buf, cfg, stats, 4, 0)
+	got := bars(t, buf.String())
+	if len(got) != len(stats) {
+		t.Fatalf("got %d bars, want %d:\n%s", len(got), len(stats), buf.String())
+	}
+	for _, bar := range got {
+		checkBar(t, bar, cfg.BarWidth)
+	}
+	if n := strings.Count(got[0], "\u2588"); n != 30 {
+		t.Errorf("75%% bar has %d glyphs, want 30", n)
+	}
+}
+
+func TestPrintStatsBarClampedWithHuman(t *testing.T) {
+	cfg := testConfig(t, "--human")
+	tests := []struct {
+		name  string
+		count int
+		total int
+	}{
+		{"rounds to 100%", 999, 1000},
+		{"exactly 100%", 1, 1},
+		{"over 100%", 2, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			printStats(&buf, cfg, []FileStat{{Ext: "go", Count: tt.count}}, tt.total, 0)
+			got := bars(t, buf.String())
+			if len(got) != 1 {
+				t.Fatalf("got %d bars, want 1:\n%s", len(got), buf.String())
+			}
+			checkBar(t, got[0], cfg.BarWidth)
+			if n := strings.Count(got[0], "\u2588"); n != cfg.BarWidth {
+				t.Errorf("bar has %d glyphs, want a full %d", n, cfg.BarWidth)
+			}
+		})
+	}
+}
+
+func TestHumanReadableSizeBoundaries(t *testing.T) {
+	tests := []struct {
+		name  string
+		units unitSystem
+		bytes int64
+		want  string
+	}{
+		{"si", siUnits, 999, "999 B"},
+		{"si", siUnits, 1000, "1.00 kB"},
+		{"si", siUnits, 1023, "1.02 kB"},
+		{"si", siUnits, 1024, "1.02 kB"},
+		{"si", siUnits, 1024 * 1024, "1.05 MB"},
+		{"binary", binaryUnits, 999, "999 B"},
+		{"binary", binaryUnits, 1000, "1000 B"},
+		{"binary", binaryUnits, 1023, "1023 B"},
+		{"binary", binaryUnits, 1024, "1.00 KB"},
+		{"binary", binaryUnits, 1024 * 1024, "1.00 MB"},
+	}
+	for _, tt := range tests {
+		if got := humanReadableSize(Config{}, tt.bytes, tt.units); got != tt.want {
+			t.Errorf("%s: humanReadableSize(%d) = %q, want %q", tt.name, tt.bytes, got, tt.want)
+		}
+	}
+}
+
+func TestParseArgsAgeBounds(t *testing.T) {
+	tests := []struct {
+		args    []string
+		wantErr bool
+	}{
+		{[]string{"--newer-than", "7d", "--older-than", "7d"}, true},
+		{[]string{"--newer-than", "1d", "--older-than", "7d"}, true},
+		{[]string{"--newer-than", "7d", "--older-than", "1d"}, false},
+	}
+	for _, tt := range tests {
+		_, err := parseArgs(append([]string{"dstat"}, tt.args...))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseArgs(%q) error = %v, want error %v", tt.args, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"os/user"
+	"strconv"
+	"sync"
+)
+
+// unknownOwner is the --by-owner row for files without owner information
+const unknownOwner = "[unknown]"
+
+// ownerNames resolves UIDs to user names for --by-owner
+// Lookups are cached since every file of a user repeats them; it is shared by all workers
+type ownerNames struct {
+	mu    sync.Mutex
+	names map[uint32]string
+}
+
+// newOwnerNames returns an empty ownerNames
+func newOwnerNames() *ownerNames {
+	return &ownerNames{names: make(map[uint32]string)}
+}
+
+// name returns the user name owning uid, or the numeric UID if it has no account
+func (o *ownerNames) name(uid uint32) string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if name, ok := o.names[uid]; ok {
+		return name
+	}
+	id := strconv.FormatUint(uint64(uid), 10)
+	name := id
+	if u, err := user.LookupId(id); err == nil {
+		name = u.Username
+	}
+	o.names[uid] = name
+	return name
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package main
+
+import "io/fs"
+
+// ownersSupported reports whether fileOwner can return file owners
+const ownersSupported = false
+
+// fileOwner never has owner information on this platform
+func fileOwner(info fs.FileInfo) (uint32, bool) {
+	return 0, false
+}
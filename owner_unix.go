@@ -0,0 +1,20 @@
+//go:build unix
+
+package main
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// ownersSupported reports whether fileOwner can return file owners
+const ownersSupported = true
+
+// fileOwner returns the UID owning info
+func fileOwner(info fs.FileInfo) (uint32, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return st.Uid, true
+}
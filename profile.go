@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+)
+
+// startProfiling starts --cpuprofile collection and returns a function that
+// stops it and writes the --memprofile heap snapshot
+// run defers the returned function so profiles are flushed on every exit path
+func startProfiling(cfg Config) (func(), error) {
+	var cpu *os.File
+	if cfg.CPUProfile != "" {
+		f, err := os.Create(cfg.CPUProfile)
+		if err != nil {
+			return nil, fmt.Errorf("creating CPU profile: %v", err)
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("starting CPU profile: %v", err)
+		}
+		cpu = f
+	}
+
+	return func() {
+		if cpu != nil {
+			pprof.StopCPUProfile()
+			cpu.Close()
+		}
+		if cfg.MemProfile != "" {
+			if err := writeHeapProfile(cfg.MemProfile); err != nil {
+				fmt.Fprintln(os.Stderr, "Error writing memory profile:", err)
+			}
+		}
+	}, nil
+}
+
+// writeHeapProfile writes a heap profile reflecting all allocations so far
+func writeHeapProfile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	runtime.GC()
+	if err := pprof.WriteHeapProfile(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// sniffLen is how much of a file http.DetectContentType looks at
+const sniffLen = 512
+
+// sniffType classifies a file by its leading bytes instead of its name
+// Parameters such as charset are dropped so text files share one row
+func sniffType(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	buf := make([]byte, sniffLen)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	mime, _, _ := strings.Cut(http.DetectContentType(buf[:n]), ";")
+	return mime, nil
+}
+
+// specialType returns the row for an entry that is not a regular file, using
+// the inode/ types shared-mime-info gives them, since opening it would fail or block
+func specialType(mode fs.FileMode) string {
+	switch {
+	case mode&fs.ModeSymlink != 0:
+		return "inode/symlink"
+	case mode&fs.ModeNamedPipe != 0:
+		return "inode/fifo"
+	case mode&fs.ModeSocket != 0:
+		return "inode/socket"
+	case mode&fs.ModeCharDevice != 0:
+		return "inode/chardevice"
+	case mode&fs.ModeDevice != 0:
+		return "inode/blockdevice"
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// dirTotal is the number and size of the counted files at or below a directory
+type dirTotal struct {
+	Count int
+	Size  int64
+}
+
+// addTree adds a counted file to its directory and every ancestor up to root
+// Files outside root (possible with --stdin) are not attributed to any node
+func (r *ScanResult) addTree(root, path string, size int64) {
+	rel, err := filepath.Rel(root, filepath.Dir(path))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return
+	}
+	for {
+		key := filepath.Join(root, rel)
+		t := r.DirTotals[key]
+		t.Count++
+		t.Size += size
+		r.DirTotals[key] = t
+		if rel == "." {
+			return
+		}
+		rel = filepath.Dir(rel)
+	}
+}
+
+// printTree prints each scanned root and its subdirectories with their subtotals
+// Levels deeper than --maxdepth are not shown but are still summed into their parents
+func printTree(w io.Writer, cfg Config, totals map[string]dirTotal) {
+	children := make(map[string][]string)
+	for k := range totals {
+		parent := filepath.Dir(k)
+		if parent != k {
+			children[parent] = append(children[parent], k)
+		}
+	}
+
+	var walk func(dir string, depth int)
+	walk = func(dir string, depth int) {
+		t := totals[dir]
+		name := dir
+		if depth > 0 {
+			name = filepath.Base(dir)
+		}
+		fmt.Fprintf(w, "%s%s  %s, %d files\n", strings.Repeat("    ", depth), name, formatSize(cfg, t.Size), t.Count)
+		if cfg.MaxDepth >= 0 && depth >= cfg.MaxDepth {
+			return
+		}
+		kids := children[dir]
+		sort.Slice(kids, func(i, j int) bool {
+			a, b := totals[kids[i]], totals[kids[j]]
+			switch {
+			case cfg.Sort == "name":
+			case cfg.BySize && a.Size != b.Size:
+				return a.Size > b.Size
+			case !cfg.BySize && a.Count != b.Count:
+				return a.Count > b.Count
+			}
+			return kids[i] < kids[j]
+		})
+		for _, k := range kids {
+			walk(k, depth+1)
+		}
+	}
+
+	for _, root := range treeRoots(cfg.Dirs) {
+		walk(filepath.Clean(root), 0)
+	}
+}
+
+// treeRoots returns the directories the tree is rooted at, with each glob
+// argument replaced by its base directory since that is what addTree credits
+func treeRoots(dirs []string) []string {
+	roots := make([]string, len(dirs))
+	for i, dir := range dirs {
+		roots[i] = dir
+		if isGlob(dir) {
+			roots[i], _ = splitGlob(dir)
+		}
+	}
+	return uniqueRoots(roots)
+}
+
+// printEmptyDirs lists the walked directories that have no counted file at
+// any depth below them, in path order
+// Since addTree credits every ancestor, a directory is empty exactly when it has no subtotal
+func printEmptyDirs(w io.Writer, res *ScanResult) {
+	empty := []string{}
+	for _, dir := range res.WalkedDirs {
+		if _, ok := res.DirTotals[dir]; !ok {
+			empty = append(empty, dir)
+		}
+	}
+	if len(empty) == 0 {
+		fmt.Fprintln(w, "No empty directories found.")
+		return
+	}
+	sort.Strings(empty)
+	for _, dir := range empty {
+		fmt.Fprintln(w, dir)
+	}
+}
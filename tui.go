@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// tuiHelp lists the keys shown at the bottom of the --tui screen
+const tuiHelp = "↑↓ move  s sort  d by-dir  enter open  ⌫ back  h hidden  q quit"
+
+// tuiView is the state of the --tui browser
+// Sorting reuses the last scan; the other toggles rescan the current directory
+type tuiView struct {
+	cfg Config
+	// parents holds the directories drilled out of, for going back up
+	parents []string
+
+	res   *ScanResult
+	stats []FileStat
+	err   error
+
+	sel, offset int
+	// rows is how many breakdown rows fit on screen, for paging
+	rows int
+}
+
+// runTUI scans cfg.Dir and lets the user browse the breakdown until q is pressed
+func runTUI(cfg Config) error {
+	screen, err := tcell.NewScreen()
+	if err != nil {
+		return err
+	}
+	if err := screen.Init(); err != nil {
+		return err
+	}
+	defer screen.Fini()
+
+	// Warnings would scribble over the screen; they are counted in the footer instead
+	cfg.Quiet = true
+	v := &tuiView{cfg: cfg}
+	v.rescan()
+	for {
+		v.draw(screen)
+		switch ev := screen.PollEvent().(type) {
+		case *tcell.EventResize:
+			screen.Sync()
+		case *tcell.EventKey:
+			if !v.handle(ev) {
+				return nil
+			}
+		}
+	}
+}
+
+// rescan walks the current directory again with the current toggles
+func (v *tuiView) rescan() {
+	// Each rescan is a new scan: --max-files starts over and no hard link
+	// counts as already seen
+	v.cfg.scan = newScanState(v.cfg)
+	res, err := walkDir(context.Background(), v.cfg)
+	v.res, v.err = res, err
+	v.cfg.totalLines = res.TotalLines
+	if err != nil {
+		v.res, v.stats = newScanResult(), nil
+		return
+	}
+	v.stats = aggregateStats(v.cfg, res)
+	v.sel = min(v.sel, max(len(v.stats)-1, 0))
+}
+
+// handle applies a key press and reports whether the browser should keep running
+func (v *tuiView) handle(ev *tcell.EventKey) bool {
+	switch ev.Key() {
+	case tcell.KeyEscape, tcell.KeyCtrlC:
+		return false
+	case tcell.KeyUp:
+		v.move(-1)
+	case tcell.KeyDown:
+		v.move(1)
+	case tcell.KeyPgUp:
+		v.move(-v.rows)
+	case tcell.KeyPgDn:
+		v.move(v.rows)
+	case tcell.KeyHome:
+		v.sel = 0
+	case tcell.KeyEnd:
+		v.sel = max(len(v.stats)-1, 0)
+	case tcell.KeyEnter, tcell.KeyRight:
+		v.open()
+	case tcell.KeyBackspace, tcell.KeyBackspace2, tcell.KeyLeft:
+		v.back()
+	case tcell.KeyRune:
+		switch ev.Rune() {
+		case 'q':
+			return false
+		case 'j':
+			v.move(1)
+		case 'k':
+			v.move(-1)
+		case 's':
+			v.cycleSort()
+			v.stats = aggregateStats(v.cfg, v.res)
+		case 'd':
+			v.cfg.ByDir = !v.cfg.ByDir
+			v.sel = 0
+			v.rescan()
+		case 'h':
+			v.cfg.IncludeHidden = !v.cfg.IncludeHidden
+			v.rescan()
+		}
+	}
+	return true
+}
+
+// move shifts the selection by delta rows, stopping at either end
+func (v *tuiView) move(delta int) {
+	v.sel = min(max(v.sel+delta, 0), max(len(v.stats)-1, 0))
+}
+
+// cycleSort switches between sorting by count, size and name
+func (v *tuiView) cycleSort() {
+	v.cfg.ByLines = false
+	switch v.cfg.Sort {
+	case "size":
+		v.cfg.Sort, v.cfg.BySize = "name", false
+	case "name":
+		v.cfg.Sort, v.cfg.BySize = "count", false
+	default:
+		v.cfg.Sort, v.cfg.BySize = "size", true
+	}
+}
+
+// open drills into the selected directory row of the --by-dir view
+// Rows that are not directories, such as "." or the folded remainder, are ignored
+func (v *tuiView) open() {
+	if !v.cfg.ByDir || len(v.stats) == 0 {
+		return
+	}
+	name := v.stats[v.sel].Ext
+	if name == "." {
+		return
+	}
+	dir := filepath.Join(v.cfg.Dir, name)
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return
+	}
+	v.parents = append(v.parents, v.cfg.Dir)
+	v.cfg.Dir = dir
+	v.sel, v.offset = 0, 0
+	v.rescan()
+}
+
+// back returns to the directory open was last called from
+func (v *tuiView) back() {
+	if len(v.parents) == 0 {
+		return
+	}
+	v.cfg.Dir = v.parents[len(v.parents)-1]
+	v.parents = v.parents[:len(v.parents)-1]
+	v.sel, v.offset = 0, 0
+	v.rescan()
+}
+
+// draw renders the header, the visible breakdown rows and the footer
+func (v *tuiView) draw(s tcell.Screen) {
+	s.Clear()
+	w, h := s.Size()
+	reverse := tcell.StyleDefault.Reverse(true)
+
+	sortBy := v.cfg.Sort
+	if sortBy == "" {
+		sortBy = "count"
+	}
+	header := fmt.Sprintf(" dstat  %s  sort: %s", v.cfg.Dir, sortBy)
+	if v.cfg.ByDir {
+		header += "  [by dir]"
+	}
+	if v.cfg.IncludeHidden {
+		header += "  [hidden]"
+	}
+	drawLine(s, 0, w, header, reverse)
+	drawLine(s, 1, w, fmt.Sprintf(" %-20s %8s %10s %8s", "Name", "Count", "Size", "Percent"), tcell.StyleDefault.Bold(true))
+
+	v.rows = max(h-4, 1)
+	if v.sel < v.offset {
+		v.offset = v.sel
+	}
+	if v.sel >= v.offset+v.rows {
+		v.offset = v.sel - v.rows + 1
+	}
+
+	if v.err != nil {
+		drawLine(s, 2, w, " Error: "+v.err.Error(), tcell.StyleDefault)
+	}
+	barWidth := min(max(w-52, 0), v.cfg.BarWidth)
+	for i := v.offset; i < len(v.stats) && i-v.offset < v.rows; i++ {
+		st := v.stats[i]
+		percent := statPercent(v.cfg, st, v.res.Total, v.res.TotalBytes)
+		barLen := min(max(int(percent/100*float64(barWidth)), 0), barWidth)
+		line := fmt.Sprintf(" %-20s %8s %10s %7s%% %s", truncateName(st.Ext, 20),
+			formatCount(v.cfg, int64(st.Count)), formatSize(v.cfg, st.Size),
+			localef(v.cfg, "%.2f", percent), barFill(v.cfg.BarChar, barLen))
+		style := tcell.StyleDefault
+		if i == v.sel {
+			style = reverse
+		}
+		drawLine(s, 2+i-v.offset, w, line, style)
+	}
+
+	footer := fmt.Sprintf(" Total: %s files, %s", formatCount(v.cfg, int64(v.res.Total)), formatSize(v.cfg, v.res.TotalBytes))
+	if n := len(v.res.Errors); n > 0 {
+		footer += fmt.Sprintf(", %d unreadable skipped", n)
+	}
+	drawLine(s, h-2, w, footer, tcell.StyleDefault)
+	drawLine(s, h-1, w, " "+tuiHelp, reverse)
+	s.Show()
+}
+
+// drawLine writes text on row y, padded with spaces to the screen width w
+func drawLine(s tcell.Screen, y, w int, text string, style tcell.Style) {
+	x := 0
+	for _, r := range text {
+		if x >= w {
+			return
+		}
+		s.SetContent(x, y, r, nil, style)
+		x++
+	}
+	for ; x < w; x++ {
+		s.SetContent(x, y, ' ', nil, style)
+	}
+}
+
+// truncateName shortens name to at most n runes, marking the cut with an ellipsis
+func truncateName(name string, n int) string {
+	runes := []rune(name)
+	if len(runes) <= n {
+		return name
+	}
+	return string(runes[:n-1]) + "…"
+}
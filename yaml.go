@@ -0,0 +1,17 @@
+package main
+
+import (
+	"io"
+
+	"gopkg.in/yaml.v3"
+)
+
+// printYAML writes the --json document to w as YAML
+func printYAML(w io.Writer, cfg Config, stats []FileStat, total int, totalBytes int64, errs []ScanError) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(newJSONReport(cfg, stats, total, totalBytes, errs)); err != nil {
+		return err
+	}
+	return enc.Close()
+}